@@ -5,11 +5,18 @@ import (
 	"github.com/KKGo-Software-engineering/workshop-summer/api/summary"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/budget"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/currency"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/eslip"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/health"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/recurring"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/slipjob"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/spender"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/storage"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -19,7 +26,7 @@ type Server struct {
 	*echo.Echo
 }
 
-func New(db *sql.DB, cfg config.Config, logger *zap.Logger) *Server {
+func New(db *sql.DB, cfg config.Config, store storage.Storage, logger *zap.Logger) *Server {
 	e := echo.New()
 
 	e.Use(middleware.Logger())
@@ -29,28 +36,104 @@ func New(db *sql.DB, cfg config.Config, logger *zap.Logger) *Server {
 
 	v1.GET("/slow", health.Slow)
 	v1.GET("/health", health.Check(db))
-	v1.POST("/upload", eslip.Upload)
 
-	v1.Use(middleware.BasicAuth(AuthCheck))
+	authHandler := auth.New(cfg.Auth, db, audit.RecordSpender)
+	v1.POST("/auth/register", authHandler.Register)
+	v1.POST("/auth/token", authHandler.Token)
+	v1.POST("/auth/revoke", authHandler.Revoke)
+
+	v1.Use(auth.Bearer(cfg.Auth, db))
+	v1.Use(middleware.BasicAuthWithConfig(middleware.BasicAuthConfig{
+		Skipper:   auth.Authenticated,
+		Validator: AuthCheck(db),
+	}))
+	v1.Use(auth.Scoped)
+
+	v1.PUT("/auth/password", authHandler.ChangePassword)
+
+	admin := auth.RequireRole(auth.RoleAdmin)
 
 	{
 		h := spender.New(cfg.FeatureFlag, db)
-		v1.GET("/spenders", h.GetAll)
-		v1.POST("/spenders", h.Create)
+		v1.GET("/spenders", h.GetAll, admin)
+		v1.POST("/spenders", h.Create, admin)
+		v1.GET("/spenders/:id", h.GetByID, auth.Owner("id"))
+		v1.PUT("/spenders/:id", h.Update, auth.Owner("id"))
+		v1.PATCH("/spenders/:id", h.Patch, auth.Owner("id"))
+		v1.DELETE("/spenders/:id", h.Delete, admin)
+		v1.PUT("/spenders/:id/role", h.UpdateRole, admin)
+		v1.PUT("/spenders/:id/base-currency", h.UpdateBaseCurrency, auth.Owner("id"))
+	}
+
+	{
+		h := currency.New(db)
+		v1.GET("/exchange-rates", h.GetRates)
+		v1.POST("/exchange-rates", h.ImportRates, admin)
 	}
 
 	{
 		h := transaction.New(db)
 		v1.POST("/transactions", h.Create)
-		v1.GET("/spenders/:spenderId/transactions", h.GetAllBySpender)
-		v1.PUT("/spenders/:spenderId/transactions/:transId", h.Update)
-		v1.DELETE("/spenders/:spenderId/transactions/:transId", h.Delete)
+		v1.GET("/transactions", h.GetAll)
+		owner := auth.Owner("spenderId")
+		v1.GET("/spenders/:spenderId/transactions", h.GetAllBySpender, owner)
+		v1.GET("/spenders/:spenderId/transactions/export", h.Export, owner)
+		v1.POST("/spenders/:spenderId/transactions/import", h.Import, owner)
+		v1.GET("/spenders/:spenderId/transactions/:transId", h.GetByID, owner)
+		v1.PUT("/spenders/:spenderId/transactions/:transId", h.Update, owner)
+		v1.PATCH("/spenders/:spenderId/transactions/:transId", h.Patch, owner)
+		v1.DELETE("/spenders/:spenderId/transactions/:transId", h.Delete, owner)
+		v1.GET("/spenders/:spenderId/transactions/trash", h.GetTrash, owner)
+		v1.POST("/spenders/:spenderId/transactions/:transId/restore", h.Restore, owner)
+	}
+
+	{
+		h := eslip.New(cfg.Upload, db, store, cfg.Scheduler.SlipMaxAttempts)
+		v1.POST("/upload", h.Upload)
+		v1.GET("/spenders/:spenderId/transactions/:transId/slips", h.GetByTransaction, auth.Owner("spenderId"))
+	}
+
+	{
+		h := slipjob.New(db)
+		owner := auth.Owner("id")
+		v1.GET("/spenders/:id/slip-jobs", h.GetAll, owner)
+		v1.GET("/spenders/:id/slip-jobs/:jobId", h.GetByID, owner)
+		v1.POST("/spenders/:id/slip-jobs/:jobId/retry", h.Retry, owner)
 	}
 
 	{
 		h := summary.New(cfg.FeatureFlag, db)
-		v1.GET("/spenders/:id/expenses/summary", h.GetExpenseSummaryHandler)
-		v1.GET("/spenders/:id/incomes/summary", h.GetIncomeSummaryHandler)
+		owner := auth.Owner("id")
+		v1.GET("/spenders/:id/expenses/summary", h.GetExpenseSummaryHandler, owner)
+		v1.GET("/spenders/:id/incomes/summary", h.GetIncomeSummaryHandler, owner)
+		v1.GET("/spenders/:id/expenses/summary/categories", h.GetExpenseCategorySummaryHandler, owner)
+		v1.GET("/spenders/:id/incomes/summary/categories", h.GetIncomeCategorySummaryHandler, owner)
+		v1.GET("/spenders/:id/balance", h.GetBalanceHandler, owner)
+	}
+
+	{
+		h := budget.New(db)
+		owner := auth.Owner("id")
+		v1.GET("/spenders/:id/budgets", h.GetAll, owner)
+		v1.POST("/spenders/:id/budgets", h.Create, owner)
+		v1.GET("/spenders/:id/budgets/status", h.GetStatus, owner)
+		v1.PUT("/spenders/:id/budgets/:budgetId", h.Update, owner)
+		v1.DELETE("/spenders/:id/budgets/:budgetId", h.Delete, owner)
+	}
+
+	{
+		h := recurring.New(db)
+		owner := auth.Owner("id")
+		v1.GET("/spenders/:id/recurrences", h.GetAll, owner)
+		v1.POST("/spenders/:id/recurrences", h.Create, owner)
+		v1.POST("/spenders/:id/recurrences/:recurringId/pause", h.Pause, owner)
+		v1.POST("/spenders/:id/recurrences/:recurringId/resume", h.Resume, owner)
+		v1.DELETE("/spenders/:id/recurrences/:recurringId", h.Delete, owner)
+	}
+
+	{
+		h := audit.New(db)
+		v1.GET("/audit", h.GetAll, admin)
 	}
 
 	return &Server{e}
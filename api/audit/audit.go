@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+const (
+	EntityTransaction = "transaction"
+	EntitySpender     = "spender"
+	EntityRecurring   = "recurring"
+)
+
+// InsertStmt is exported for the tests of the packages that record changes.
+const InsertStmt = `INSERT INTO audit_log (actor_id, action, entity, entity_id, before, after, parent_id, span_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+// Execer is implemented by *sql.Tx. Entries are written through the
+// transaction of the change they describe, so both commit or neither does.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Entry is one change of an entity. Before is nil for a creation and After
+// is nil for a deletion. ActorID is nil for changes made by background jobs.
+type Entry struct {
+	ID        int64     `json:"id"`
+	ActorID   *int64    `json:"actor_id"`
+	Action    string    `json:"action"`
+	Entity    string    `json:"entity"`
+	EntityID  int64     `json:"entity_id"`
+	Before    any       `json:"before"`
+	After     any       `json:"after"`
+	ParentID  string    `json:"parent_id"`
+	SpanID    string    `json:"span_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func marshal(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// lib/pq sends []byte as bytea, which jsonb does not accept.
+	return string(b), nil
+}
+
+// Write stores e through tx.
+func Write(ctx context.Context, tx Execer, e Entry) error {
+	before, err := marshal(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshal(e.After)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, InsertStmt, e.ActorID, e.Action, e.Entity, e.EntityID, before, after, e.ParentID, e.SpanID)
+	return err
+}
+
+// Record stores a change made by the request: the actor is the authenticated
+// spender and the request is identified by the parent-id and span-id it is
+// logged with.
+func Record(c echo.Context, tx Execer, e Entry) error {
+	if sp, ok := auth.Current(c); ok {
+		e.ActorID = &sp.ID
+	}
+	e.ParentID, e.SpanID = mlog.IDs(c)
+	return Write(c.Request().Context(), tx, e)
+}
+
+// RecordSpender records a spender change made by the auth handlers, which
+// cannot import this package. A nil before is a creation and a nil after a
+// deletion.
+func RecordSpender(c echo.Context, tx *sql.Tx, spenderID int64, before, after any) error {
+	action := ActionUpdate
+	switch {
+	case before == nil:
+		action = ActionCreate
+	case after == nil:
+		action = ActionDelete
+	}
+	return Record(c, tx, Entry{Action: action, Entity: EntitySpender, EntityID: spenderID, Before: before, After: after})
+}
@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/mlog"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newContext(query string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/audit"+query, nil)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("marshal before and after as JSON", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectExec(InsertStmt).
+			WithArgs(nil, ActionUpdate, EntitySpender, int64(1), `{"name":"HongJot"}`, `{"name":"JotHong"}`, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := Write(context.Background(), db, Entry{
+			Action:   ActionUpdate,
+			Entity:   EntitySpender,
+			EntityID: 1,
+			Before:   map[string]string{"name": "HongJot"},
+			After:    map[string]string{"name": "JotHong"},
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("store a missing state as NULL", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectExec(InsertStmt).
+			WithArgs(nil, ActionDelete, EntityTransaction, int64(7), `{"id":7}`, nil, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := Write(context.Background(), db, Entry{Action: ActionDelete, Entity: EntityTransaction, EntityID: 7, Before: map[string]int{"id": 7}})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRecord(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	e := echo.New()
+	e.Use(mlog.Middleware(zap.NewNop()))
+	e.POST("/", func(c echo.Context) error {
+		auth.Set(c, auth.Spender{ID: 3, Role: auth.RoleAdmin})
+		return Record(c, db, Entry{Action: ActionCreate, Entity: EntitySpender, EntityID: 1, After: map[string]int{"id": 1}})
+	})
+	mock.ExpectExec(InsertStmt).
+		WithArgs(int64(3), ActionCreate, EntitySpender, int64(1), nil, `{"id":1}`, "parent-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-Parent-ID", "parent-1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordSpender(t *testing.T) {
+	cases := []struct {
+		name          string
+		before, after any
+		want          string
+	}{
+		{"registration", nil, map[string]int{"id": 1}, ActionCreate},
+		{"change", map[string]int{"id": 1}, map[string]int{"id": 1}, ActionUpdate},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(InsertStmt).
+				WithArgs(nil, tc.want, EntitySpender, int64(1), sqlmock.AnyArg(), `{"id":1}`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			tx, _ := db.Begin()
+
+			e := echo.New()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+			err := RecordSpender(c, tx, 1, tc.before, tc.after)
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		query     string
+		wantStmt  string
+		wantArgs  []any
+		wantLimit int
+	}{
+		{"defaults", "", listStmt + " ORDER BY id DESC LIMIT $1", []any{51}, 50},
+		{"entity and actor", "?entity=transaction&entity_id=7&actor_id=3", listStmt + " WHERE entity = $1 AND entity_id = $2 AND actor_id = $3 ORDER BY id DESC LIMIT $4", []any{"transaction", int64(7), int64(3), 51}, 50},
+		{"time window", "?from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z", listStmt + " WHERE created_at >= $1 AND created_at < $2 ORDER BY id DESC LIMIT $3", []any{from, to, 51}, 50},
+		{"cursor and limit", "?cursor=100&limit=500", listStmt + " WHERE id < $1 ORDER BY id DESC LIMIT $2", []any{int64(100), 201}, 200},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newContext(tc.query)
+
+			q, err := parseQuery(c)
+			stmt, args := q.statement()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStmt, stmt)
+			assert.Equal(t, tc.wantArgs, args)
+			assert.Equal(t, tc.wantLimit, q.limit)
+		})
+	}
+}
+
+func TestParseQueryInvalid(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"?entity=budget", "entity must be transaction, spender or recurring"},
+		{"?actor_id=abc", "actor_id must be a positive integer"},
+		{"?limit=0", "limit must be a positive integer"},
+		{"?from=2024-05-01", "from and to must be RFC 3339 timestamps"},
+		{"?from=2024-06-01T00:00:00Z&to=2024-05-01T00:00:00Z", "to must be after from"},
+	}
+
+	for _, tc := range cases {
+		c, _ := newContext(tc.query)
+
+		_, err := parseQuery(c)
+
+		assert.EqualError(t, err, tc.want, tc.query)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	columns := []string{"id", "actor_id", "action", "entity", "entity_id", "before", "after", "parent_id", "span_id", "created_at"}
+	at := time.Date(2024, time.May, 18, 10, 0, 0, 0, time.UTC)
+
+	t.Run("list entries with a next cursor", func(t *testing.T) {
+		c, rec := newContext("?entity=spender&limit=1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows(columns).
+			AddRow(9, 3, "update", "spender", 1, []byte(`{"name":"HongJot"}`), []byte(`{"name":"JotHong"}`), "p", "s", at).
+			AddRow(8, nil, "create", "spender", 1, nil, []byte(`{"name":"HongJot"}`), "", "", at)
+		mock.ExpectQuery(listStmt+" WHERE entity = $1 ORDER BY id DESC LIMIT $2").WithArgs("spender", 2).WillReturnRows(rows)
+
+		err := New(db).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"entries": [{"id":9,"actor_id":3,"action":"update","entity":"spender","entity_id":1,
+				"before":{"name":"HongJot"},"after":{"name":"JotHong"},"parent_id":"p","span_id":"s","created_at":"2024-05-18T10:00:00Z"}],
+			"next_cursor": "9"
+		}`, rec.Body.String())
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		c, rec := newContext("?entity=budget")
+
+		err := New(nil).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		c, rec := newContext("")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(listStmt + " ORDER BY id DESC LIMIT $1").WillReturnError(assert.AnError)
+
+		err := New(db).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
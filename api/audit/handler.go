@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+const listStmt = `SELECT id, actor_id, action, entity, entity_id, before, after, parent_id, span_id, created_at FROM audit_log`
+
+var (
+	ErrInvalidEntity = errors.New("entity must be transaction, spender or recurring")
+	ErrInvalidTime   = errors.New("from and to must be RFC 3339 timestamps")
+	ErrInvalidRange  = errors.New("to must be after from")
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type pageResponse struct {
+	Entries    []Entry `json:"entries"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+type handler struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *handler {
+	return &handler{db}
+}
+
+// query holds the SQL conditions built from the GET /audit query string.
+type query struct {
+	limit      int
+	conditions []string
+	args       []any
+}
+
+func (q *query) where(condition string, arg any) {
+	q.args = append(q.args, arg)
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, len(q.args)))
+}
+
+// statement returns the newest entries first. One extra row tells whether
+// there is a next page.
+func (q query) statement() (string, []any) {
+	stmt := listStmt
+	if len(q.conditions) > 0 {
+		stmt += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+	stmt += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(q.args)+1)
+	return stmt, append(append([]any{}, q.args...), q.limit+1)
+}
+
+func parseInt(c echo.Context, name string) (int64, bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < 1 {
+		return 0, false, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return v, true, nil
+}
+
+func parseTime(c echo.Context, name string) (time.Time, bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false, ErrInvalidTime
+	}
+	return t, true, nil
+}
+
+func parseQuery(c echo.Context) (query, error) {
+	q := query{limit: defaultLimit}
+	limit, ok, err := parseInt(c, "limit")
+	if err != nil {
+		return q, err
+	}
+	if ok {
+		q.limit = min(int(limit), maxLimit)
+	}
+
+	if entity := c.QueryParam("entity"); entity != "" {
+		if entity != EntityTransaction && entity != EntitySpender && entity != EntityRecurring {
+			return q, ErrInvalidEntity
+		}
+		q.where("entity = $%d", entity)
+	}
+	for _, f := range []struct{ param, condition string }{
+		{"entity_id", "entity_id = $%d"},
+		{"actor_id", "actor_id = $%d"},
+		{"cursor", "id < $%d"},
+	} {
+		v, ok, err := parseInt(c, f.param)
+		if err != nil {
+			return q, err
+		}
+		if ok {
+			q.where(f.condition, v)
+		}
+	}
+
+	from, hasFrom, err := parseTime(c, "from")
+	if err != nil {
+		return q, err
+	}
+	to, hasTo, err := parseTime(c, "to")
+	if err != nil {
+		return q, err
+	}
+	if hasFrom && hasTo && !to.After(from) {
+		return q, ErrInvalidRange
+	}
+	if hasFrom {
+		q.where("created_at >= $%d", from)
+	}
+	if hasTo {
+		q.where("created_at < $%d", to)
+	}
+	return q, nil
+}
+
+// GetAll lists the audit trail newest first, filtered by entity, entity id,
+// actor and a created_at window. It is meant for admins.
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	q, err := parseQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	stmt, args := q.statement()
+	rows, err := h.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		logger.Error("query audit log error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query audit log error"})
+	}
+	defer rows.Close()
+
+	res := pageResponse{Entries: []Entry{}}
+	for rows.Next() {
+		var e Entry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.Entity, &e.EntityID, &before, &after, &e.ParentID, &e.SpanID, &e.CreatedAt)
+		if err != nil {
+			logger.Error("scan audit log error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan audit log error"})
+		}
+		if before != nil {
+			e.Before = json.RawMessage(before)
+		}
+		if after != nil {
+			e.After = json.RawMessage(after)
+		}
+		res.Entries = append(res.Entries, e)
+	}
+	if len(res.Entries) > q.limit {
+		res.Entries = res.Entries[:q.limit]
+		res.NextCursor = strconv.FormatInt(res.Entries[q.limit-1].ID, 10)
+	}
+	return c.JSON(http.StatusOK, res)
+}
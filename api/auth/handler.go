@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dberr"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	existStmt    = `SELECT EXISTS (SELECT 1 FROM spender WHERE email = $1 AND deleted_at IS NULL);`
+	registerStmt = `INSERT INTO spender (name, email, password_hash) VALUES ($1, $2, $3) RETURNING id, role, base_currency;`
+	passwordStmt = `SELECT id, name, email, role, base_currency, password_hash FROM spender WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;`
+	// changeStmt bumps token_version so tokens issued with the old password
+	// stop working.
+	changeStmt = `UPDATE spender SET password_hash = $1, token_version = token_version + 1 WHERE id = $2;`
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type registerRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type tokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+type revokeRequest struct {
+	Token string `json:"token"`
+}
+
+// Recorder writes a spender change to the audit log in the database
+// transaction of the change. before is nil for a registration. The audit
+// package depends on this one, so api.New passes its recorder in.
+type Recorder func(c echo.Context, tx *sql.Tx, spenderID int64, before, after any) error
+
+// spenderRecord is a spender as the audit log keeps it: the fields the
+// spender package records, never the password hash.
+type spenderRecord struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Role         string `json:"role,omitempty"`
+	BaseCurrency string `json:"base_currency,omitempty"`
+}
+
+type handler struct {
+	db     *sql.DB
+	tokens *tokens
+	record Recorder
+}
+
+func New(cfg config.Auth, db *sql.DB, record Recorder) *handler {
+	return &handler{db: db, tokens: newTokens(cfg, db), record: record}
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters")
+	}
+	return nil
+}
+
+func (h handler) Register(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var req registerRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid request body"})
+	}
+	if req.Name == "" || req.Email == "" {
+		return c.JSON(http.StatusBadRequest, Err{Message: "name and email are required"})
+	}
+	if err := validatePassword(req.Password); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	var exists bool
+	if err := h.db.QueryRowContext(ctx, existStmt, req.Email).Scan(&exists); err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	if exists {
+		return c.JSON(http.StatusConflict, Err{Message: "email is already registered"})
+	}
+
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		logger.Error("hash password error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "hash password error"})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "insert spender error"})
+	}
+	defer tx.Rollback()
+
+	sp := spenderRecord{Name: req.Name, Email: req.Email}
+	err = tx.QueryRowContext(ctx, registerStmt, req.Name, req.Email, hash).Scan(&sp.ID, &sp.Role, &sp.BaseCurrency)
+	if dberr.IsUnique(err) {
+		// registered concurrently since the existence check
+		return c.JSON(http.StatusConflict, Err{Message: "email is already registered"})
+	}
+	if err == nil {
+		err = h.record(c, tx, sp.ID, nil, sp)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logger.Error("insert spender error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "insert spender error"})
+	}
+
+	logger.Info("register successfully", zap.Int64("id", sp.ID))
+	return c.JSON(http.StatusCreated, Spender{ID: sp.ID, Name: sp.Name, Email: sp.Email, Role: sp.Role})
+}
+
+// ChangePassword replaces the caller's password after checking the old one.
+// Every access and refresh token issued to them before stops working.
+func (h handler) ChangePassword(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	sp, ok := Current(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, Err{Message: "unauthorized"})
+	}
+
+	var req changePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid request body"})
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "update password error"})
+	}
+	defer tx.Rollback()
+
+	var before spenderRecord
+	var hash string
+	err = tx.QueryRowContext(ctx, passwordStmt, sp.ID).Scan(&before.ID, &before.Name, &before.Email, &before.Role, &before.BaseCurrency, &hash)
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	if !ComparePassword(hash, req.OldPassword) {
+		return c.JSON(http.StatusUnauthorized, Err{Message: ErrInvalidCredential.Error()})
+	}
+
+	newHash, err := HashPassword(req.NewPassword)
+	if err != nil {
+		logger.Error("hash password error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "hash password error"})
+	}
+	_, err = tx.ExecContext(ctx, changeStmt, newHash, sp.ID)
+	if err == nil {
+		// the record leaves the hash out, so before and after are alike
+		err = h.record(c, tx, sp.ID, before, before)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logger.Error("update password error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "update password error"})
+	}
+
+	logger.Info("change password successfully", zap.Int64("id", sp.ID))
+	return c.JSON(http.StatusOK, map[string]string{"message": "password changed"})
+}
+
+// Token issues an access and refresh token pair. It supports the "password"
+// grant (email and password) and the "refresh_token" grant, which rotates the
+// refresh token by revoking the one presented. The new pair of a refresh is
+// issued for the spender as they are in the database, so it is refused once
+// the spender is deleted and carries their current role.
+func (h handler) Token(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var req tokenRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid request body"})
+	}
+
+	var sp Spender
+	var scopes []string
+	switch req.GrantType {
+	case "password":
+		var err error
+		scopes, err = parseScope(req.Scope)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+		}
+		sp, err = Check(ctx, h.db, req.Email, req.Password)
+		if errors.Is(err, ErrInvalidCredential) {
+			return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+		}
+		if err != nil {
+			logger.Error("check credential error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "check credential error"})
+		}
+	case "refresh_token":
+		claims, err := h.tokens.Parse(ctx, req.RefreshToken, tokenRefresh)
+		if errors.Is(err, ErrInvalidToken) {
+			return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+		}
+		if err != nil {
+			logger.Error("parse refresh token error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "parse token error"})
+		}
+		sp, err = h.tokens.Spender(ctx, claims)
+		if errors.Is(err, ErrInvalidToken) {
+			return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+		}
+		if err != nil {
+			logger.Error("query spender error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+		}
+		scopes = sp.Scopes
+		err = h.tokens.Revoke(ctx, claims)
+		if errors.Is(err, ErrInvalidToken) {
+			// used concurrently by another request
+			return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+		}
+		if err != nil {
+			logger.Error("revoke refresh token error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "revoke token error"})
+		}
+	default:
+		return c.JSON(http.StatusBadRequest, Err{Message: "unsupported grant_type"})
+	}
+
+	pair, err := h.tokens.Issue(sp, scopes)
+	if err != nil {
+		logger.Error("sign token error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "sign token error"})
+	}
+	return c.JSON(http.StatusOK, pair)
+}
+
+// Revoke invalidates an access or refresh token before it expires.
+func (h handler) Revoke(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var req revokeRequest
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid request body"})
+	}
+
+	claims, err := h.tokens.Parse(ctx, req.Token, "")
+	if errors.Is(err, ErrInvalidToken) {
+		return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+	}
+	if err != nil {
+		logger.Error("parse token error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "parse token error"})
+	}
+
+	err = h.tokens.Revoke(ctx, claims)
+	if errors.Is(err, ErrInvalidToken) {
+		return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+	}
+	if err != nil {
+		logger.Error("revoke token error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "revoke token error"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newContext(body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+// recorded keeps the audit entries a handler records.
+type recorded struct {
+	id            int64
+	before, after any
+}
+
+func recorder(entries *[]recorded) Recorder {
+	return func(_ echo.Context, _ *sql.Tx, id int64, before, after any) error {
+		*entries = append(*entries, recorded{id, before, after})
+		return nil
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("register spender successfully", func(t *testing.T) {
+		c, rec := newContext(`{"name": "HongJot", "email": "hong@jot.ok", "password": "secret-password"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existStmt).WithArgs("hong@jot.ok").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectBegin()
+		mock.ExpectQuery(registerStmt).WithArgs("HongJot", "hong@jot.ok", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "role", "base_currency"}).AddRow(1, "spender", "THB"))
+		mock.ExpectCommit()
+		var entries []recorded
+
+		err := New(config.Auth{}, db, recorder(&entries)).Register(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok", "role": "spender"}`, rec.Body.String())
+		assert.Equal(t, []recorded{{1, nil, spenderRecord{ID: 1, Name: "HongJot", Email: "hong@jot.ok", Role: "spender", BaseCurrency: "THB"}}}, entries)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("register failed when password is too short", func(t *testing.T) {
+		c, rec := newContext(`{"name": "HongJot", "email": "hong@jot.ok", "password": "short"}`)
+
+		err := New(config.Auth{}, nil, nil).Register(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "password must be at least 8 characters"}`, rec.Body.String())
+	})
+
+	t.Run("register failed when email is missing", func(t *testing.T) {
+		c, rec := newContext(`{"name": "HongJot", "password": "secret-password"}`)
+
+		err := New(config.Auth{}, nil, nil).Register(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("register failed when email already exists", func(t *testing.T) {
+		c, rec := newContext(`{"name": "HongJot", "email": "hong@jot.ok", "password": "secret-password"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existStmt).WithArgs("hong@jot.ok").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		err := New(config.Auth{}, db, nil).Register(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("register failed when email is taken concurrently", func(t *testing.T) {
+		c, rec := newContext(`{"name": "HongJot", "email": "hong@jot.ok", "password": "secret-password"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existStmt).WithArgs("hong@jot.ok").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectBegin()
+		mock.ExpectQuery(registerStmt).WillReturnError(&pq.Error{Code: "23505"})
+		mock.ExpectRollback()
+
+		err := New(config.Auth{}, db, nil).Register(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("register failed on database", func(t *testing.T) {
+		c, rec := newContext(`{"name": "HongJot", "email": "hong@jot.ok", "password": "secret-password"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(existStmt).WithArgs("hong@jot.ok").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectBegin()
+		mock.ExpectQuery(registerStmt).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		err := New(config.Auth{}, db, nil).Register(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+func passwordRows(hash string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "email", "role", "base_currency", "password_hash"}).
+		AddRow(1, "HongJot", "hong@jot.ok", "spender", "THB", hash)
+}
+
+func TestChangePassword(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+
+	t.Run("change password successfully", func(t *testing.T) {
+		c, rec := newContext(`{"old_password": "old-password", "new_password": "new-password"}`)
+		Set(c, Spender{ID: 1, Name: "HongJot", Email: "hong@jot.ok"})
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(passwordStmt).WithArgs(1).WillReturnRows(passwordRows(string(hash)))
+		mock.ExpectExec(changeStmt).WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		var entries []recorded
+
+		err := New(config.Auth{}, db, recorder(&entries)).ChangePassword(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		sp := spenderRecord{ID: 1, Name: "HongJot", Email: "hong@jot.ok", Role: "spender", BaseCurrency: "THB"}
+		assert.Equal(t, []recorded{{1, sp, sp}}, entries)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("change password failed when old password is wrong", func(t *testing.T) {
+		c, rec := newContext(`{"old_password": "wrong-password", "new_password": "new-password"}`)
+		Set(c, Spender{ID: 1, Name: "HongJot", Email: "hong@jot.ok"})
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(passwordStmt).WithArgs(1).WillReturnRows(passwordRows(string(hash)))
+		mock.ExpectRollback()
+		var entries []recorded
+
+		err := New(config.Auth{}, db, recorder(&entries)).ChangePassword(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Empty(t, entries)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("change password failed without authenticated spender", func(t *testing.T) {
+		c, rec := newContext(`{"old_password": "old-password", "new_password": "new-password"}`)
+
+		err := New(config.Auth{}, nil, nil).ChangePassword(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -1,12 +1,193 @@
 package auth
 
 import (
-	"crypto/subtle"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/labstack/echo/v4"
+)
+
+const key = "spender"
+
+const findStmt = `SELECT id, name, email, role, token_version, password_hash FROM spender WHERE email = $1 AND deleted_at IS NULL;`
+
+const (
+	RoleAdmin   = "admin"
+	RoleSpender = "spender"
 )
 
-func Check(username, password string) bool {
-	isUserValid := subtle.ConstantTimeCompare([]byte(username), []byte("user")) == 1
-	isPassValid := subtle.ConstantTimeCompare([]byte(password), []byte("secret")) == 1
+var (
+	ErrInvalidCredential = errors.New("invalid email or password")
+	ErrForbidden         = errors.New("you are not allowed to access this spender")
+)
+
+type Spender struct {
+	ID     int64    `json:"id"`
+	Name   string   `json:"name"`
+	Email  string   `json:"email"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"-"`
+	// TokenVersion is the spender's token_version, which every token issued
+	// to them carries.
+	TokenVersion int `json:"-"`
+}
+
+func (sp Spender) HasScope(scope string) bool {
+	return slices.Contains(sp.Scopes, scope)
+}
+
+func (sp Spender) IsAdmin() bool {
+	return sp.Role == RoleAdmin
+}
+
+// Check looks up the spender by email and verifies the password against the
+// stored bcrypt hash.
+func Check(ctx context.Context, db *sql.DB, email, password string) (Spender, error) {
+	var sp Spender
+	var hash string
+	err := db.QueryRowContext(ctx, findStmt, email).Scan(&sp.ID, &sp.Name, &sp.Email, &sp.Role, &sp.TokenVersion, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		ComparePassword("", password)
+		return Spender{}, ErrInvalidCredential
+	}
+	if err != nil {
+		return Spender{}, err
+	}
+
+	if !ComparePassword(hash, password) {
+		return Spender{}, ErrInvalidCredential
+	}
+	sp.Scopes = DefaultScopes
+	return sp, nil
+}
+
+// Set stores the authenticated spender in the echo context.
+func Set(c echo.Context, sp Spender) {
+	c.Set(key, sp)
+}
+
+// Current returns the authenticated spender of the request.
+func Current(c echo.Context) (Spender, bool) {
+	sp, ok := c.Get(key).(Spender)
+	return sp, ok
+}
+
+// CanAccess reports whether the caller may read or modify the ledger of the
+// given spender: admins may access everyone, spenders only themselves.
+func CanAccess(c echo.Context, spenderID int64) bool {
+	sp, ok := Current(c)
+	if !ok {
+		return false
+	}
+	return sp.IsAdmin() || sp.ID == spenderID
+}
+
+// Owner rejects requests whose spender id path parameter does not belong to
+// the caller with 403, unless the caller is an admin.
+func Owner(param string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !Authenticated(c) {
+				return c.JSON(http.StatusUnauthorized, Err{Message: "unauthorized"})
+			}
+			spenderID, err := strconv.ParseInt(c.Param(param), 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, Err{Message: "invalid spender id"})
+			}
+			if !CanAccess(c, spenderID) {
+				return Forbidden(c, ErrForbidden.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireRole only lets callers with one of the given roles through to the
+// route and answers everyone else with 403.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sp, ok := Current(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, Err{Message: "unauthorized"})
+			}
+			if !slices.Contains(roles, sp.Role) {
+				return Forbidden(c, "requires role: "+strings.Join(roles, " or "))
+			}
+			return next(c)
+		}
+	}
+}
+
+// Scoped answers callers whose token lacks the scope of the request with 403:
+// "read" for GET, HEAD and OPTIONS and "write" for everything else. Requests
+// of callers that are not authenticated yet are passed through.
+func Scoped(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sp, ok := Current(c)
+		if !ok {
+			return next(c)
+		}
+		scope := ScopeWrite
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			scope = ScopeRead
+		}
+		if !sp.HasScope(scope) {
+			return Forbidden(c, "requires scope: "+scope)
+		}
+		return next(c)
+	}
+}
+
+// Forbidden writes the 403 body shared by every authorization failure.
+func Forbidden(c echo.Context, message string) error {
+	return c.JSON(http.StatusForbidden, Err{Message: "forbidden: " + message})
+}
+
+// Authenticated reports whether an earlier middleware already resolved the
+// caller, so BasicAuth can be skipped for bearer token requests.
+func Authenticated(c echo.Context) bool {
+	_, ok := Current(c)
+	return ok
+}
+
+// Bearer validates "Authorization: Bearer <access token>" headers and stores
+// the spender, as they are in the database, and the scopes of the token in the
+// context. Tokens of a deleted spender, or issued before their password was
+// changed, are rejected. Requests without a bearer token are passed through
+// untouched.
+func Bearer(cfg config.Auth, db *sql.DB) echo.MiddlewareFunc {
+	t := newTokens(cfg, db)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			scheme, raw, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "bearer") {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			claims, err := t.Parse(ctx, strings.TrimSpace(raw), tokenAccess)
+			var sp Spender
+			if err == nil {
+				sp, err = t.Spender(ctx, claims)
+			}
+			if errors.Is(err, ErrInvalidToken) {
+				return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+			}
+			if err != nil {
+				return err
+			}
 
-	return isUserValid && isPassValid
+			Set(c, sp)
+			return next(c)
+		}
+	}
 }
@@ -1,21 +1,163 @@
 package auth
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
 
 func TestCheckUsernameAndPassword(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+
 	cases := []struct {
-		username string
+		email    string
 		password string
-		want     bool
+		found    bool
+		want     error
 	}{
-		{"user", "secret", true},
-		{"user", "wrong-secret", false},
+		{"hong@jot.ok", "secret-password", true, nil},
+		{"hong@jot.ok", "wrong-secret", true, ErrInvalidCredential},
+		{"unknown@jot.ok", "secret-password", false, ErrInvalidCredential},
 	}
 
 	for _, tc := range cases {
-		got := Check(tc.username, tc.password)
-		if got != tc.want {
-			t.Errorf("Check(%s, %s) = %v; want %v", tc.username, tc.password, got, tc.want)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		rows := sqlmock.NewRows([]string{"id", "name", "email", "role", "token_version", "password_hash"})
+		if tc.found {
+			rows.AddRow(1, "HongJot", tc.email, "spender", 0, string(hash))
+		}
+		mock.ExpectQuery(findStmt).WithArgs(tc.email).WillReturnRows(rows)
+
+		sp, err := Check(context.Background(), db, tc.email, tc.password)
+
+		assert.Equal(t, tc.want, err, "Check(%s, %s)", tc.email, tc.password)
+		if tc.want == nil {
+			assert.Equal(t, Spender{ID: 1, Name: "HongJot", Email: tc.email, Role: RoleSpender, Scopes: DefaultScopes}, sp)
 		}
+		db.Close()
+	}
+}
+
+func TestCheckDatabaseError(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	mock.ExpectQuery(findStmt).WithArgs("hong@jot.ok").WillReturnError(assert.AnError)
+
+	_, err := Check(context.Background(), db, "hong@jot.ok", "secret-password")
+
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestOwnerMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller         *Spender
+		spenderID      string
+		wantStatusCode int
+	}{
+		{"spender reads own ledger", &Spender{ID: 1, Role: RoleSpender}, "1", http.StatusOK},
+		{"spender reads another ledger", &Spender{ID: 2, Role: RoleSpender}, "1", http.StatusForbidden},
+		{"admin reads another ledger", &Spender{ID: 2, Role: RoleAdmin}, "1", http.StatusOK},
+		{"invalid spender id", &Spender{ID: 1, Role: RoleSpender}, "abc", http.StatusBadRequest},
+		{"unauthenticated caller", nil, "1", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("spenderId")
+			c.SetParamValues(tc.spenderID)
+			if tc.caller != nil {
+				Set(c, *tc.caller)
+			}
+
+			h := Owner("spenderId")(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			err := h(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatusCode, rec.Code)
+		})
+	}
+}
+
+func TestRequireRoleMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller         *Spender
+		wantStatusCode int
+		wantBody       string
+	}{
+		{"admin is allowed", &Spender{ID: 1, Role: RoleAdmin}, http.StatusOK, ""},
+		{"spender is forbidden", &Spender{ID: 1, Role: RoleSpender}, http.StatusForbidden, `{"message": "forbidden: requires role: admin"}`},
+		{"unauthenticated caller", nil, http.StatusUnauthorized, `{"message": "unauthorized"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			if tc.caller != nil {
+				Set(c, *tc.caller)
+			}
+
+			h := RequireRole(RoleAdmin)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			err := h(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatusCode, rec.Code)
+			if tc.wantBody != "" {
+				assert.JSONEq(t, tc.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestScopedMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		caller         *Spender
+		wantStatusCode int
+	}{
+		{"read scope can read", http.MethodGet, &Spender{ID: 1, Scopes: []string{ScopeRead}}, http.StatusOK},
+		{"read scope cannot write", http.MethodPost, &Spender{ID: 1, Scopes: []string{ScopeRead}}, http.StatusForbidden},
+		{"read scope cannot delete", http.MethodDelete, &Spender{ID: 1, Scopes: []string{ScopeRead}}, http.StatusForbidden},
+		{"write scope cannot read", http.MethodGet, &Spender{ID: 1, Scopes: []string{ScopeWrite}}, http.StatusForbidden},
+		{"default scopes can write", http.MethodPatch, &Spender{ID: 1, Scopes: DefaultScopes}, http.StatusOK},
+		{"unauthenticated caller passes through", http.MethodPost, nil, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(tc.method, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			if tc.caller != nil {
+				Set(c, *tc.caller)
+			}
+
+			err := Scoped(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantStatusCode, rec.Code)
+		})
 	}
 }
@@ -0,0 +1,25 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+const minPasswordLength = 8
+
+// dummyHash is compared against when the spender does not exist so an
+// unknown email costs the same time as a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("hongjot-dummy-password"), bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func ComparePassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const expiredStmt = `DELETE FROM revoked_token WHERE expires_at < $1;`
+
+// Purger removes revoked tokens that have expired. They are refused for
+// their expiry alone, so keeping them only grows the table.
+type Purger struct {
+	db       *sql.DB
+	interval time.Duration
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+func NewPurger(db *sql.DB, interval time.Duration, logger *zap.Logger) *Purger {
+	return &Purger{db: db, interval: interval, logger: logger, now: time.Now}
+}
+
+// Start purges immediately and then on every interval until ctx is
+// cancelled.
+func (p *Purger) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Run(ctx); err != nil {
+			p.logger.Error("purge expired revoked tokens", zap.Error(err))
+		} else if n > 0 {
+			p.logger.Info("purged expired revoked tokens", zap.Int64("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run removes every revoked token that has expired by now and reports how
+// many were removed.
+func (p *Purger) Run(ctx context.Context) (int64, error) {
+	result, err := p.db.ExecContext(ctx, expiredStmt, p.now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
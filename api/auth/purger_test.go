@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestPurgerRun(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	now := time.Date(2024, time.May, 18, 0, 0, 0, 0, time.UTC)
+	p := NewPurger(db, time.Hour, zap.NewNop())
+	p.now = func() time.Time { return now }
+	mock.ExpectExec(expiredStmt).WithArgs(now).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := p.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
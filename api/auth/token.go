@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+const (
+	revokeStmt  = `INSERT INTO revoked_token (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING;`
+	revokedStmt = `SELECT EXISTS (SELECT 1 FROM revoked_token WHERE jti = $1);`
+	spenderStmt = `SELECT id, name, email, role, token_version FROM spender WHERE id = $1 AND deleted_at IS NULL;`
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// DefaultScopes are granted to BasicAuth callers and to token requests that
+// do not ask for a narrower scope.
+var DefaultScopes = []string{ScopeRead, ScopeWrite}
+
+type Claims struct {
+	Name   string   `json:"name"`
+	Email  string   `json:"email"`
+	Role   string   `json:"role"`
+	Scopes []string `json:"scopes"`
+	Type   string   `json:"typ"`
+	// Version is the token_version of the spender when it was issued.
+	Version int `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+func (c Claims) Spender() (Spender, error) {
+	id, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return Spender{}, ErrInvalidToken
+	}
+	return Spender{ID: id, Name: c.Name, Email: c.Email, Role: c.Role, Scopes: c.Scopes}, nil
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+type tokens struct {
+	cfg config.Auth
+	db  *sql.DB
+	now func() time.Time
+}
+
+func newTokens(cfg config.Auth, db *sql.DB) *tokens {
+	return &tokens{cfg: cfg, db: db, now: time.Now}
+}
+
+// parseScope narrows the requested space separated scopes to the ones the
+// spender is allowed to have.
+func parseScope(scope string) ([]string, error) {
+	if strings.TrimSpace(scope) == "" {
+		return DefaultScopes, nil
+	}
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(DefaultScopes, s) {
+			return nil, ErrInvalidScope
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+func (t *tokens) sign(sp Spender, scopes []string, typ string, ttl time.Duration) (string, error) {
+	now := t.now()
+	claims := Claims{
+		Name:    sp.Name,
+		Email:   sp.Email,
+		Role:    sp.Role,
+		Scopes:  scopes,
+		Type:    typ,
+		Version: sp.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   strconv.FormatInt(sp.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(t.cfg.JWTSigningKey))
+}
+
+func (t *tokens) Issue(sp Spender, scopes []string) (TokenPair, error) {
+	access, err := t.sign(sp, scopes, tokenAccess, t.cfg.AccessTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := t.sign(sp, scopes, tokenRefresh, t.cfg.RefreshTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(t.cfg.AccessTokenTTL.Seconds()),
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// Parse verifies the signature, expiry and type of a token and makes sure it
+// has not been revoked. An empty typ accepts both access and refresh tokens.
+func (t *tokens) Parse(ctx context.Context, raw, typ string) (Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(t.cfg.JWTSigningKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(t.now), jwt.WithExpirationRequired())
+	if err != nil || (typ != "" && claims.Type != typ) || claims.ID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var revoked bool
+	if err := t.db.QueryRowContext(ctx, revokedStmt, claims.ID).Scan(&revoked); err != nil {
+		return Claims{}, err
+	}
+	if revoked {
+		return Claims{}, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Spender reads the spender a token was issued to as they are now, so a role
+// change since the token was issued is not carried over by the token. A token
+// of a deleted spender, or of an older token_version, is invalid. Only the
+// scopes come from the token.
+func (t *tokens) Spender(ctx context.Context, claims Claims) (Spender, error) {
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return Spender{}, ErrInvalidToken
+	}
+	var sp Spender
+	err = t.db.QueryRowContext(ctx, spenderStmt, id).Scan(&sp.ID, &sp.Name, &sp.Email, &sp.Role, &sp.TokenVersion)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && sp.TokenVersion != claims.Version) {
+		return Spender{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Spender{}, err
+	}
+	sp.Scopes = claims.Scopes
+	return sp, nil
+}
+
+// Revoke invalidates the token. It fails with ErrInvalidToken when the token
+// was already revoked, so of two requests racing to use one refresh token
+// only the first gets a new pair.
+func (t *tokens) Revoke(ctx context.Context, claims Claims) error {
+	result, err := t.db.ExecContext(ctx, revokeStmt, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testAuthConfig = config.Auth{
+	JWTSigningKey:   "test-signing-key",
+	AccessTokenTTL:  15 * time.Minute,
+	RefreshTokenTTL: 24 * time.Hour,
+}
+
+var testSpender = Spender{ID: 1, Name: "HongJot", Email: "hong@jot.ok", Role: RoleSpender}
+
+var spenderCols = []string{"id", "name", "email", "role", "token_version"}
+
+func expectSpender(mock sqlmock.Sqlmock, tokenVersion int) {
+	mock.ExpectQuery(spenderStmt).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(spenderCols).AddRow(1, "HongJot", "hong@jot.ok", "spender", tokenVersion))
+}
+
+func expectNotRevoked(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(revokedStmt).WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+}
+
+func TestParseScope(t *testing.T) {
+	cases := []struct {
+		scope   string
+		want    []string
+		wantErr error
+	}{
+		{"", DefaultScopes, nil},
+		{"read", []string{ScopeRead}, nil},
+		{"read write read", []string{ScopeRead, ScopeWrite}, nil},
+		{"admin", nil, ErrInvalidScope},
+	}
+
+	for _, tc := range cases {
+		got, err := parseScope(tc.scope)
+		assert.Equal(t, tc.wantErr, err, "parseScope(%q)", tc.scope)
+		assert.Equal(t, tc.want, got, "parseScope(%q)", tc.scope)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	t.Run("issue and parse access token", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		expectNotRevoked(mock)
+		tk := newTokens(testAuthConfig, db)
+
+		pair, err := tk.Issue(testSpender, []string{ScopeRead})
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", pair.TokenType)
+		assert.Equal(t, int64(900), pair.ExpiresIn)
+
+		claims, err := tk.Parse(context.Background(), pair.AccessToken, tokenAccess)
+		assert.NoError(t, err)
+		sp, err := claims.Spender()
+		assert.NoError(t, err)
+		assert.Equal(t, Spender{ID: 1, Name: "HongJot", Email: "hong@jot.ok", Role: RoleSpender, Scopes: []string{ScopeRead}}, sp)
+	})
+
+	t.Run("refresh token is not accepted as access token", func(t *testing.T) {
+		tk := newTokens(testAuthConfig, nil)
+		pair, _ := tk.Issue(testSpender, DefaultScopes)
+
+		_, err := tk.Parse(context.Background(), pair.RefreshToken, tokenAccess)
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		tk := newTokens(testAuthConfig, nil)
+		tk.now = func() time.Time { return time.Now().Add(-time.Hour) }
+		pair, _ := tk.Issue(testSpender, DefaultScopes)
+		tk.now = time.Now
+
+		_, err := tk.Parse(context.Background(), pair.AccessToken, tokenAccess)
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("token signed with another key is rejected", func(t *testing.T) {
+		other := newTokens(config.Auth{JWTSigningKey: "other-key", AccessTokenTTL: time.Minute}, nil)
+		pair, _ := other.Issue(testSpender, DefaultScopes)
+
+		_, err := newTokens(testAuthConfig, nil).Parse(context.Background(), pair.AccessToken, tokenAccess)
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("revoked token is rejected", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(revokedStmt).WithArgs(sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		tk := newTokens(testAuthConfig, db)
+		pair, _ := tk.Issue(testSpender, DefaultScopes)
+
+		_, err := tk.Parse(context.Background(), pair.AccessToken, tokenAccess)
+
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestTokenHandler(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+
+	t.Run("issue token with password grant", func(t *testing.T) {
+		c, rec := newContext(`{"grant_type": "password", "email": "hong@jot.ok", "password": "secret-password", "scope": "read"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(findStmt).WithArgs("hong@jot.ok").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role", "token_version", "password_hash"}).
+				AddRow(1, "HongJot", "hong@jot.ok", "spender", 0, string(hash)))
+
+		err := New(testAuthConfig, db, nil).Token(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var pair TokenPair
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pair))
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEmpty(t, pair.RefreshToken)
+		assert.Equal(t, "read", pair.Scope)
+	})
+
+	t.Run("password grant with wrong password", func(t *testing.T) {
+		c, rec := newContext(`{"grant_type": "password", "email": "hong@jot.ok", "password": "wrong-password"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		mock.ExpectQuery(findStmt).WithArgs("hong@jot.ok").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role", "token_version", "password_hash"}).
+				AddRow(1, "HongJot", "hong@jot.ok", "spender", 0, string(hash)))
+
+		err := New(testAuthConfig, db, nil).Token(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("refresh token grant rotates the refresh token", func(t *testing.T) {
+		pair, _ := newTokens(testAuthConfig, nil).Issue(testSpender, DefaultScopes)
+		c, rec := newContext(`{"grant_type": "refresh_token", "refresh_token": "` + pair.RefreshToken + `"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		expectNotRevoked(mock)
+		expectSpender(mock, 0)
+		mock.ExpectExec(revokeStmt).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := New(testAuthConfig, db, nil).Token(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refresh token grant issues the current role", func(t *testing.T) {
+		admin := Spender{ID: 1, Name: "HongJot", Email: "hong@jot.ok", Role: RoleAdmin}
+		pair, _ := newTokens(testAuthConfig, nil).Issue(admin, DefaultScopes)
+		c, rec := newContext(`{"grant_type": "refresh_token", "refresh_token": "` + pair.RefreshToken + `"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		expectNotRevoked(mock)
+		expectSpender(mock, 0)
+		mock.ExpectExec(revokeStmt).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := New(testAuthConfig, db, nil).Token(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var res TokenPair
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		var claims Claims
+		_, err = jwt.ParseWithClaims(res.AccessToken, &claims, func(*jwt.Token) (interface{}, error) {
+			return []byte(testAuthConfig.JWTSigningKey), nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, RoleSpender, claims.Role)
+	})
+
+	t.Run("refresh token grant is refused for a deleted spender", func(t *testing.T) {
+		pair, _ := newTokens(testAuthConfig, nil).Issue(testSpender, DefaultScopes)
+		c, rec := newContext(`{"grant_type": "refresh_token", "refresh_token": "` + pair.RefreshToken + `"}`)
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		expectNotRevoked(mock)
+		mock.ExpectQuery(spenderStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows(spenderCols))
+
+		err := New(testAuthConfig, db, nil).Token(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unsupported grant type", func(t *testing.T) {
+		c, rec := newContext(`{"grant_type": "client_credentials"}`)
+
+		err := New(testAuthConfig, nil, nil).Token(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestRefreshTokenUsedTwice(t *testing.T) {
+	pair, _ := newTokens(testAuthConfig, nil).Issue(testSpender, DefaultScopes)
+	c, rec := newContext(`{"grant_type": "refresh_token", "refresh_token": "` + pair.RefreshToken + `"}`)
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	expectNotRevoked(mock)
+	expectSpender(mock, 0)
+	// revoked by a concurrent request since it was parsed
+	mock.ExpectExec(revokeStmt).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := New(testAuthConfig, db, nil).Token(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeHandler(t *testing.T) {
+	pair, _ := newTokens(testAuthConfig, nil).Issue(testSpender, DefaultScopes)
+	c, rec := newContext(`{"token": "` + pair.AccessToken + `"}`)
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	expectNotRevoked(mock)
+	mock.ExpectExec(revokeStmt).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := New(testAuthConfig, db, nil).Revoke(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBearerMiddleware(t *testing.T) {
+	pair, _ := newTokens(testAuthConfig, nil).Issue(testSpender, DefaultScopes)
+
+	tests := []struct {
+		name           string
+		header         string
+		expect         func(sqlmock.Sqlmock)
+		wantStatusCode int
+		wantSpender    bool
+	}{
+		{"valid access token", "Bearer " + pair.AccessToken, func(mock sqlmock.Sqlmock) {
+			expectNotRevoked(mock)
+			expectSpender(mock, 0)
+		}, http.StatusOK, true},
+		{"token of a deleted spender", "Bearer " + pair.AccessToken, func(mock sqlmock.Sqlmock) {
+			expectNotRevoked(mock)
+			mock.ExpectQuery(spenderStmt).WithArgs(1).WillReturnRows(sqlmock.NewRows(spenderCols))
+		}, http.StatusUnauthorized, false},
+		{"token issued before a password change", "Bearer " + pair.AccessToken, func(mock sqlmock.Sqlmock) {
+			expectNotRevoked(mock)
+			expectSpender(mock, 1)
+		}, http.StatusUnauthorized, false},
+		{"refresh token as access token", "Bearer " + pair.RefreshToken, func(sqlmock.Sqlmock) {}, http.StatusUnauthorized, false},
+		{"malformed token", "Bearer not-a-token", func(sqlmock.Sqlmock) {}, http.StatusUnauthorized, false},
+		{"no bearer token passes through", "", func(sqlmock.Sqlmock) {}, http.StatusOK, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			tc.expect(mock)
+
+			e := echo.New()
+			e.Use(Bearer(testAuthConfig, db))
+			e.GET("/", func(c echo.Context) error {
+				assert.Equal(t, tc.wantSpender, Authenticated(c))
+				return c.NoContent(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.header)
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.wantStatusCode, rec.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
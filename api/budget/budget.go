@@ -0,0 +1,325 @@
+package budget
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dberr"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// periodLayout is the format of a budget period: one calendar month.
+const periodLayout = "2006-01"
+
+var (
+	ErrInvalidSpender  = errors.New("invalid spender")
+	ErrInvalidBudget   = errors.New("invalid budget id")
+	ErrInvalidPeriod   = errors.New("period must be in YYYY-MM format")
+	ErrCategory        = errors.New("category is required")
+	ErrLimit           = errors.New("limit must not be negative")
+	ErrNotFound        = errors.New("budget not found")
+	ErrSpenderNotFound = errors.New("spender not found")
+	ErrDuplicate       = errors.New("budget for this category and period already exists")
+)
+
+const (
+	listStmt   = `SELECT id, spender_id, category, period, amount_limit FROM budget WHERE spender_id = $1 AND ($2 = '' OR period = $2) ORDER BY period DESC, category;`
+	insertStmt = `INSERT INTO budget (spender_id, category, period, amount_limit) VALUES ($1, $2, $3, $4) RETURNING id;`
+	updateStmt = `UPDATE budget SET category = $1, period = $2, amount_limit = $3 WHERE id = $4 AND spender_id = $5;`
+	deleteStmt = `DELETE FROM budget WHERE id = $1 AND spender_id = $2;`
+	statusStmt = `SELECT
+	    b.category,
+	    b.amount_limit,
+	    COALESCE(SUM(t.base_amount), 0) AS spent
+	FROM
+	    budget b
+	LEFT JOIN transaction_base t
+	    ON t.spender_id = b.spender_id
+	    AND t.category = b.category
+	    AND t.transaction_type = 'expense'
+	    AND t.date >= $3 AND t.date < $4
+	WHERE
+	    b.spender_id = $1 AND b.period = $2
+	GROUP BY
+	    b.id, b.category, b.amount_limit
+	ORDER BY
+	    b.category;`
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type Budget struct {
+	ID        int          `json:"id"`
+	SpenderID int          `json:"spender_id"`
+	Category  string       `json:"category"`
+	Period    string       `json:"period"`
+	Limit     money.Amount `json:"limit"`
+}
+
+type request struct {
+	Category string       `json:"category"`
+	Period   string       `json:"period"`
+	Limit    money.Amount `json:"limit"`
+}
+
+// Status compares the limit of one category with what the spender has
+// actually spent on it during the period.
+type Status struct {
+	Category    string       `json:"category"`
+	Limit       money.Amount `json:"limit"`
+	Spent       money.Amount `json:"spent"`
+	Remaining   money.Amount `json:"remaining"`
+	PercentUsed float64      `json:"percent_used"`
+	OverBudget  bool         `json:"over_budget"`
+}
+
+type statusResponse struct {
+	Period     string   `json:"period"`
+	Categories []Status `json:"categories"`
+}
+
+type handler struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func New(db *sql.DB) *handler {
+	return &handler{db: db, now: time.Now}
+}
+
+func bindMessage(err error) string {
+	if cause := money.Cause(err); cause != nil {
+		return cause.Error()
+	}
+	return "invalid request body"
+}
+
+func parsePeriod(raw string) (time.Time, error) {
+	p, err := time.Parse(periodLayout, raw)
+	if err != nil {
+		return time.Time{}, ErrInvalidPeriod
+	}
+	return p, nil
+}
+
+func (r request) validate() error {
+	if r.Category == "" {
+		return ErrCategory
+	}
+	if _, err := parsePeriod(r.Period); err != nil {
+		return err
+	}
+	if r.Limit < 0 {
+		return ErrLimit
+	}
+	return nil
+}
+
+func newStatus(category string, limit, spent money.Amount) Status {
+	s := Status{
+		Category:   category,
+		Limit:      limit,
+		Spent:      spent,
+		Remaining:  limit - spent,
+		OverBudget: spent > limit,
+	}
+	if limit > 0 {
+		s.PercentUsed = math.Round(float64(spent)/float64(limit)*10000) / 100
+	}
+	return s
+}
+
+func spenderID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, ErrInvalidSpender
+	}
+	return id, nil
+}
+
+func budgetID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("budgetId"))
+	if err != nil {
+		return 0, ErrInvalidBudget
+	}
+	return id, nil
+}
+
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	period := c.QueryParam("period")
+	if period != "" {
+		if _, err := parsePeriod(period); err != nil {
+			return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+		}
+	}
+
+	rows, err := h.db.QueryContext(ctx, listStmt, spID, period)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	defer rows.Close()
+
+	budgets := []Budget{}
+	for rows.Next() {
+		var b Budget
+		err := rows.Scan(&b.ID, &b.SpenderID, &b.Category, &b.Period, &b.Limit)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
+		}
+		budgets = append(budgets, b)
+	}
+
+	return c.JSON(http.StatusOK, budgets)
+}
+
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: bindMessage(err)})
+	}
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	b := Budget{SpenderID: spID, Category: req.Category, Period: req.Period, Limit: req.Limit}
+	err = h.db.QueryRowContext(ctx, insertStmt, b.SpenderID, b.Category, b.Period, b.Limit).Scan(&b.ID)
+	if dberr.IsForeignKey(err) {
+		return c.JSON(http.StatusNotFound, Err{Message: ErrSpenderNotFound.Error()})
+	}
+	if dberr.IsUnique(err) {
+		return c.JSON(http.StatusConflict, Err{Message: ErrDuplicate.Error()})
+	}
+	if err != nil {
+		logger.Error("insert budget error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "insert budget error"})
+	}
+
+	return c.JSON(http.StatusCreated, b)
+}
+
+func (h handler) Update(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	id, err := budgetID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: bindMessage(err)})
+	}
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	result, err := h.db.ExecContext(ctx, updateStmt, req.Category, req.Period, req.Limit, id, spID)
+	if dberr.IsUnique(err) {
+		return c.JSON(http.StatusConflict, Err{Message: ErrDuplicate.Error()})
+	}
+	if err != nil {
+		logger.Error("update budget error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "update budget error"})
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return c.JSON(http.StatusNotFound, Err{Message: ErrNotFound.Error()})
+	}
+
+	return c.JSON(http.StatusOK, Budget{ID: id, SpenderID: spID, Category: req.Category, Period: req.Period, Limit: req.Limit})
+}
+
+func (h handler) Delete(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	id, err := budgetID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	result, err := h.db.ExecContext(ctx, deleteStmt, id, spID)
+	if err != nil {
+		logger.Error("delete budget error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "delete budget error"})
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return c.JSON(http.StatusNotFound, Err{Message: ErrNotFound.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetStatus reports every budget of a month against the spender's expenses in
+// that month. The period defaults to the current month.
+func (h handler) GetStatus(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	period := c.QueryParam("period")
+	if period == "" {
+		period = h.now().Format(periodLayout)
+	}
+	start, err := parsePeriod(period)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	rows, err := h.db.QueryContext(ctx, statusStmt, spID, period, start, start.AddDate(0, 1, 0))
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	defer rows.Close()
+
+	res := statusResponse{Period: period, Categories: []Status{}}
+	for rows.Next() {
+		var category string
+		var limit, spent money.Amount
+		if err := rows.Scan(&category, &limit, &spent); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
+		}
+		res.Categories = append(res.Categories, newStatus(category, limit, spent))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
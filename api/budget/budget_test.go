@@ -0,0 +1,236 @@
+package budget
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func newContext(method, target, body string, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names := []string{"id", "budgetId"}
+	c.SetParamNames(names[:len(params)]...)
+	c.SetParamValues(params...)
+	return c, rec
+}
+
+func TestNewStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit money.Amount
+		spent money.Amount
+		want  Status
+	}{
+		{"under budget", 1000, 250, Status{Category: "Food", Limit: 1000, Spent: 250, Remaining: 750, PercentUsed: 25}},
+		{"exactly on budget", 1000, 1000, Status{Category: "Food", Limit: 1000, Spent: 1000, Remaining: 0, PercentUsed: 100}},
+		{"over budget", 300, 400, Status{Category: "Food", Limit: 300, Spent: 400, Remaining: -100, PercentUsed: 133.33, OverBudget: true}},
+		{"zero limit", 0, 0, Status{Category: "Food"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, newStatus("Food", tc.limit, tc.spent))
+		})
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	t.Run("list budgets of a period", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?period=2024-05", "", "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"id", "spender_id", "category", "period", "amount_limit"}).
+			AddRow(1, 1, "Food", "2024-05", 5000)
+		mock.ExpectQuery(listStmt).WithArgs(1, "2024-05").WillReturnRows(rows)
+
+		err := New(db).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id":1,"spender_id":1,"category":"Food","period":"2024-05","limit":5000}]`, rec.Body.String())
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?period=May", "", "1")
+
+		err := New(nil).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"period must be in YYYY-MM format"}`, rec.Body.String())
+	})
+}
+
+func TestCreate(t *testing.T) {
+	body := `{"category":"Food","period":"2024-05","limit":5000}`
+
+	t.Run("create budget successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", body, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(insertStmt).WithArgs(1, "Food", "2024-05", money.MustParse("5000")).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+		err := New(db).Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id":7,"spender_id":1,"category":"Food","period":"2024-05","limit":5000}`, rec.Body.String())
+	})
+
+	t.Run("duplicate budget", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", body, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(insertStmt).WillReturnError(&pq.Error{Code: "23505"})
+
+		err := New(db).Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("unknown spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", body, "99")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(insertStmt).WillReturnError(&pq.Error{Code: "23503"})
+
+		err := New(db).Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"message":"spender not found"}`, rec.Body.String())
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		tests := []struct {
+			body string
+			want string
+		}{
+			{`{"period":"2024-05","limit":1}`, "category is required"},
+			{`{"category":"Food","period":"2024-5-1","limit":1}`, "period must be in YYYY-MM format"},
+			{`{"category":"Food","period":"2024-05","limit":-1}`, "limit must not be negative"},
+			{`{"category":"Food","period":"2024-05","limit":0.125}`, "amount must not have more than 2 decimal places"},
+		}
+		for _, tc := range tests {
+			c, rec := newContext(http.MethodPost, "/", tc.body, "1")
+
+			err := New(nil).Create(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, `{"message":"`+tc.want+`"}`, rec.Body.String())
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	body := `{"category":"Food","period":"2024-05","limit":6000}`
+
+	t.Run("update budget successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", body, "1", "7")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectExec(updateStmt).WithArgs("Food", "2024-05", money.MustParse("6000"), 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := New(db).Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":7,"spender_id":1,"category":"Food","period":"2024-05","limit":6000}`, rec.Body.String())
+	})
+
+	t.Run("budget not found", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, "/", body, "1", "7")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectExec(updateStmt).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := New(db).Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Run("delete budget successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/", "", "1", "7")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectExec(deleteStmt).WithArgs(7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := New(db).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+
+	t.Run("invalid budget id", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "/", "", "1", "abc")
+
+		err := New(nil).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGetStatus(t *testing.T) {
+	t.Run("status of the current month by default", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/", "", "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		start := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows([]string{"category", "amount_limit", "spent"}).
+			AddRow("Food", 1000, 1200).
+			AddRow("Travel", 2000, 500)
+		mock.ExpectQuery(statusStmt).WithArgs(1, "2024-05", start, start.AddDate(0, 1, 0)).WillReturnRows(rows)
+
+		h := New(db)
+		h.now = func() time.Time { return time.Date(2024, time.May, 18, 10, 0, 0, 0, time.UTC) }
+		err := h.GetStatus(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"period":"2024-05","categories":[
+			{"category":"Food","limit":1000,"spent":1200,"remaining":-200,"percent_used":120,"over_budget":true},
+			{"category":"Travel","limit":2000,"spent":500,"remaining":1500,"percent_used":25,"over_budget":false}
+		]}`, rec.Body.String())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?period=2024-05", "", "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(statusStmt).WillReturnError(driver.ErrBadConn)
+
+		err := New(db).GetStatus(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/caarlos0/env/v10"
 )
@@ -14,6 +15,11 @@ type Config struct {
 	Database    Database
 	Server      Server
 	FeatureFlag FeatureFlag
+	Auth        Auth
+	Scheduler   Scheduler
+	Currency    Currency
+	Storage     Storage
+	Upload      Upload
 }
 
 func (c Config) PostgresURI() string {
@@ -28,8 +34,62 @@ type Database struct {
 	PostgresURI string `env:"DATABASE_POSTGRES_URI,required"`
 }
 
+type Auth struct {
+	JWTSigningKey   string        `env:"JWT_SIGNING_KEY,required"`
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" envDefault:"720h"`
+}
+
+type Scheduler struct {
+	RecurringInterval time.Duration `env:"RECURRING_INTERVAL" envDefault:"1h"`
+	// TrashRetention is how long a deleted transaction can still be restored
+	// before the purge job removes it for good.
+	TrashRetention time.Duration `env:"TRASH_RETENTION" envDefault:"720h"`
+	PurgeInterval  time.Duration `env:"PURGE_INTERVAL" envDefault:"24h"`
+	// RevokedTokenPurgeInterval is how often expired revoked tokens are
+	// dropped.
+	RevokedTokenPurgeInterval time.Duration `env:"REVOKED_TOKEN_PURGE_INTERVAL" envDefault:"1h"`
+	// SlipWorkers read uploaded slips into draft expenses. A worker holds a
+	// job for SlipLease; a failed attempt is retried after SlipRetryBackoff,
+	// doubled on every further failure, until SlipMaxAttempts are used up.
+	SlipWorkers      int           `env:"SLIP_WORKERS" envDefault:"2"`
+	SlipPollInterval time.Duration `env:"SLIP_POLL_INTERVAL" envDefault:"5s"`
+	SlipLease        time.Duration `env:"SLIP_LEASE" envDefault:"5m"`
+	SlipRetryBackoff time.Duration `env:"SLIP_RETRY_BACKOFF" envDefault:"30s"`
+	SlipMaxAttempts  int           `env:"SLIP_MAX_ATTEMPTS" envDefault:"5"`
+}
+
+type Currency struct {
+	ExchangeRateFile string `env:"EXCHANGE_RATE_FILE"`
+}
+
+// Storage selects where uploaded slips are kept: a local directory or an
+// S3-compatible bucket.
+type Storage struct {
+	Backend  string `env:"STORAGE_BACKEND" envDefault:"local"`
+	LocalDir string `env:"STORAGE_LOCAL_DIR" envDefault:"data/uploads"`
+	// PublicURL, when set, is the base of the locations returned for stored
+	// objects, such as a CDN in front of the bucket.
+	PublicURL   string `env:"STORAGE_PUBLIC_URL"`
+	S3Endpoint  string `env:"STORAGE_S3_ENDPOINT"`
+	S3Region    string `env:"STORAGE_S3_REGION" envDefault:"us-east-1"`
+	S3Bucket    string `env:"STORAGE_S3_BUCKET"`
+	S3AccessKey string `env:"STORAGE_S3_ACCESS_KEY"`
+	S3SecretKey string `env:"STORAGE_S3_SECRET_KEY"`
+	S3PathStyle bool   `env:"STORAGE_S3_PATH_STYLE"`
+}
+
+// Upload limits what POST /upload accepts, in bytes.
+type Upload struct {
+	MaxFileSize    int64 `env:"UPLOAD_MAX_FILE_SIZE" envDefault:"10485760"`
+	MaxRequestSize int64 `env:"UPLOAD_MAX_REQUEST_SIZE" envDefault:"31457280"`
+}
+
 type FeatureFlag struct {
 	EnableCreateSpender bool `env:"ENABLE_CREATE_SPENDER"`
+	// EnableSoftDeleteSpender makes DELETE /spenders/:id mark the spender as
+	// deleted and hide their transactions instead of removing both for good.
+	EnableSoftDeleteSpender bool `env:"ENABLE_SOFT_DELETE_SPENDER"`
 }
 
 func Env(key string) string {
@@ -71,6 +131,31 @@ func parse(envPrefix string) (Config, error) {
 		return Config{}, errors.New("failed to parse feature flag config:" + err.Error())
 	}
 
+	authconf := &Auth{}
+	if err := env.ParseWithOptions(authconf, opts); err != nil {
+		return Config{}, errors.New("failed to parse auth config:" + err.Error())
+	}
+
+	schedconf := &Scheduler{}
+	if err := env.ParseWithOptions(schedconf, opts); err != nil {
+		return Config{}, errors.New("failed to parse scheduler config:" + err.Error())
+	}
+
+	currconf := &Currency{}
+	if err := env.ParseWithOptions(currconf, opts); err != nil {
+		return Config{}, errors.New("failed to parse currency config:" + err.Error())
+	}
+
+	storconf := &Storage{}
+	if err := env.ParseWithOptions(storconf, opts); err != nil {
+		return Config{}, errors.New("failed to parse storage config:" + err.Error())
+	}
+
+	uploadconf := &Upload{}
+	if err := env.ParseWithOptions(uploadconf, opts); err != nil {
+		return Config{}, errors.New("failed to parse upload config:" + err.Error())
+	}
+
 	port := Env("SERVER_PORT")
 	if port == "" {
 		port = "8080"
@@ -84,8 +169,14 @@ func parse(envPrefix string) (Config, error) {
 			Port: port,
 		},
 		FeatureFlag: FeatureFlag{
-			EnableCreateSpender: feats.EnableCreateSpender,
+			EnableCreateSpender:     feats.EnableCreateSpender,
+			EnableSoftDeleteSpender: feats.EnableSoftDeleteSpender,
 		},
+		Auth:      *authconf,
+		Scheduler: *schedconf,
+		Currency:  *currconf,
+		Storage:   *storconf,
+		Upload:    *uploadconf,
 	}, nil
 }
 
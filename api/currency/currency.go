@@ -0,0 +1,27 @@
+package currency
+
+import (
+	"errors"
+	"strings"
+)
+
+// Default is the currency of amounts recorded before currencies existed and
+// of spenders who never chose a base currency.
+const Default = "THB"
+
+var ErrInvalid = errors.New("currency must be a 3-letter ISO 4217 code")
+
+// Normalize upper-cases an ISO 4217 code such as "usd" and rejects anything
+// that is not three letters.
+func Normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", ErrInvalid
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalid
+		}
+	}
+	return code, nil
+}
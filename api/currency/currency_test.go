@@ -0,0 +1,144 @@
+package currency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, code := range []string{"usd", " THB ", "Eur"} {
+		got, err := Normalize(code)
+		assert.NoError(t, err)
+		assert.Len(t, got, 3)
+		assert.Equal(t, strings.ToUpper(strings.TrimSpace(code)), got)
+	}
+	for _, code := range []string{"", "US", "USDT", "U$D", "12A"} {
+		_, err := Normalize(code)
+		assert.ErrorIs(t, err, ErrInvalid, code)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	t.Run("parse rates", func(t *testing.T) {
+		rates, err := ParseCSV(strings.NewReader("date,base,quote,rate\n2024-05-01,USD,THB,36.75\n2024-05-01,eur,THB,39.5\n"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Rate{
+			{Date: "2024-05-01", Base: "USD", Quote: "THB", Rate: 36.75},
+			{Date: "2024-05-01", Base: "eur", Quote: "THB", Rate: 39.5},
+		}, rates)
+	})
+
+	t.Run("reject unknown header", func(t *testing.T) {
+		_, err := ParseCSV(strings.NewReader("day,from,to,rate\n2024-05-01,USD,THB,36.75\n"))
+
+		assert.EqualError(t, err, "csv header must be date,base,quote,rate")
+	})
+
+	t.Run("reject bad rate", func(t *testing.T) {
+		_, err := ParseCSV(strings.NewReader("date,base,quote,rate\n2024-05-01,USD,THB,abc\n"))
+
+		assert.EqualError(t, err, "line 2: rate must be a number")
+	})
+}
+
+func TestSave(t *testing.T) {
+	t.Run("upsert rates in one transaction", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(upsertRateStmt).WithArgs("USD", "THB", "2024-05-01", 36.75).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(upsertRateStmt).WithArgs("EUR", "THB", "2024-05-01", 39.5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := Save(context.Background(), db, []Rate{
+			{Date: "2024-05-01", Base: "usd", Quote: "thb", Rate: 36.75},
+			{Date: "2024-05-01", Base: "EUR", Quote: "THB", Rate: 39.5},
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid rate is rejected before touching the database", func(t *testing.T) {
+		tests := []struct {
+			rate Rate
+			want string
+		}{
+			{Rate{Date: "01/05/2024", Base: "USD", Quote: "THB", Rate: 1}, "rate 1: date must be in YYYY-MM-DD format"},
+			{Rate{Date: "2024-05-01", Base: "USD", Quote: "USD", Rate: 1}, "rate 1: base and quote must differ"},
+			{Rate{Date: "2024-05-01", Base: "USD", Quote: "THB", Rate: 0}, "rate 1: rate must be greater than 0"},
+			{Rate{Date: "2024-05-01", Base: "US", Quote: "THB", Rate: 1}, "rate 1: currency must be a 3-letter ISO 4217 code"},
+		}
+		for _, tc := range tests {
+			err := Save(context.Background(), nil, []Rate{tc.rate})
+
+			assert.EqualError(t, err, tc.want)
+			assert.True(t, IsInvalid(err))
+		}
+	})
+}
+
+func TestImportRates(t *testing.T) {
+	t.Run("import csv body", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("date,base,quote,rate\n2024-05-01,USD,THB,36.75\n"))
+		req.Header.Set(echo.HeaderContentType, "text/csv")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(upsertRateStmt).WithArgs("USD", "THB", "2024-05-01", 36.75).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := New(db).ImportRates(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"imported": 1}`, rec.Body.String())
+	})
+
+	t.Run("invalid json rate", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`[{"date":"2024-05-01","base":"USD","quote":"THB","rate":-1}]`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := New(nil).ImportRates(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "rate 1: rate must be greater than 0"}`, rec.Body.String())
+	})
+}
+
+func TestGetRates(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?base=usd", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"rate_date", "base_currency", "quote_currency", "rate"}).AddRow("2024-05-01", "USD", "THB", 36.75)
+	mock.ExpectQuery(listRateStmt).WithArgs("USD", "").WillReturnRows(rows)
+
+	err := New(db).GetRates(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"date":"2024-05-01","base":"USD","quote":"THB","rate":36.75}]`, rec.Body.String())
+}
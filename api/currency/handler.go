@@ -0,0 +1,99 @@
+package currency
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	listRateStmt = `SELECT to_char(rate_date, 'YYYY-MM-DD'), base_currency, quote_currency, rate FROM exchange_rate
+WHERE ($1 = '' OR base_currency = $1) AND ($2 = '' OR quote_currency = $2)
+ORDER BY rate_date DESC, base_currency, quote_currency;`
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type handler struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *handler {
+	return &handler{db}
+}
+
+func normalizeFilter(code string) (string, error) {
+	if code == "" {
+		return "", nil
+	}
+	return Normalize(code)
+}
+
+func (h handler) GetRates(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	base, err := normalizeFilter(c.QueryParam("base"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	quote, err := normalizeFilter(c.QueryParam("quote"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	rows, err := h.db.QueryContext(ctx, listRateStmt, base, quote)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	defer rows.Close()
+
+	rates := []Rate{}
+	for rows.Next() {
+		var r Rate
+		if err := rows.Scan(&r.Date, &r.Base, &r.Quote, &r.Rate); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
+		}
+		rates = append(rates, r)
+	}
+
+	return c.JSON(http.StatusOK, rates)
+}
+
+// ImportRates stores rates sent either as a JSON array or, with a text/csv
+// content type, in the same CSV format as the startup rate file.
+func (h handler) ImportRates(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var rates []Rate
+	var err error
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		rates, err = ParseCSV(c.Request().Body)
+	} else {
+		err = c.Bind(&rates)
+	}
+	if err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid request body"})
+	}
+
+	err = Save(ctx, h.db, rates)
+	if IsInvalid(err) {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if err != nil {
+		logger.Error("save exchange rates error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "save exchange rates error"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"imported": len(rates)})
+}
@@ -0,0 +1,136 @@
+package currency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+const (
+	upsertRateStmt = `INSERT INTO exchange_rate (base_currency, quote_currency, rate_date, rate) VALUES ($1, $2, $3, $4)
+ON CONFLICT (base_currency, quote_currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate;`
+)
+
+// Rate is the price of one unit of Base in Quote on Date.
+type Rate struct {
+	Date  string  `json:"date"`
+	Base  string  `json:"base"`
+	Quote string  `json:"quote"`
+	Rate  float64 `json:"rate"`
+}
+
+func (r Rate) validate() (Rate, error) {
+	var err error
+	if _, err = time.Parse(dateLayout, r.Date); err != nil {
+		return r, errors.New("date must be in YYYY-MM-DD format")
+	}
+	if r.Base, err = Normalize(r.Base); err != nil {
+		return r, err
+	}
+	if r.Quote, err = Normalize(r.Quote); err != nil {
+		return r, err
+	}
+	if r.Base == r.Quote {
+		return r, errors.New("base and quote must differ")
+	}
+	if r.Rate <= 0 {
+		return r, errors.New("rate must be greater than 0")
+	}
+	return r, nil
+}
+
+// ParseCSV reads rates from a file with a date,base,quote,rate header line.
+func ParseCSV(r io.Reader) ([]Rate, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := strings.Join(records[0], ",")
+	if strings.ToLower(strings.TrimSpace(header)) != "date,base,quote,rate" {
+		return nil, errors.New("csv header must be date,base,quote,rate")
+	}
+
+	rates := make([]Rate, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		v, err := strconv.ParseFloat(strings.TrimSpace(rec[3]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: rate must be a number", i+2)
+		}
+		rates = append(rates, Rate{
+			Date:  strings.TrimSpace(rec[0]),
+			Base:  rec[1],
+			Quote: rec[2],
+			Rate:  v,
+		})
+	}
+	return rates, nil
+}
+
+// invalidError marks an error of a rate rather than of the database.
+type invalidError struct{ error }
+
+// IsInvalid reports whether err is a rate that Save rejected.
+func IsInvalid(err error) bool {
+	var e invalidError
+	return errors.As(err, &e)
+}
+
+// validateAll checks every rate and normalizes its currency codes in place.
+func validateAll(rates []Rate) error {
+	for i := range rates {
+		var err error
+		if rates[i], err = rates[i].validate(); err != nil {
+			return invalidError{fmt.Errorf("rate %d: %w", i+1, err)}
+		}
+	}
+	return nil
+}
+
+// Save validates rates and upserts them in one database transaction, so a
+// bad line leaves the stored rates untouched. An invalid rate is reported
+// before the database is touched, with an error for which IsInvalid is true.
+func Save(ctx context.Context, db *sql.DB, rates []Rate) error {
+	if err := validateAll(rates); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range rates {
+		if _, err := tx.ExecContext(ctx, upsertRateStmt, r.Base, r.Quote, r.Date, r.Rate); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// LoadFile saves the rates of a CSV file and returns how many were read.
+func LoadFile(ctx context.Context, db *sql.DB, name string) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	rates, err := ParseCSV(f)
+	if err != nil {
+		return 0, err
+	}
+	return len(rates), Save(ctx, db, rates)
+}
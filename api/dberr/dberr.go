@@ -0,0 +1,37 @@
+package dberr
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes of the constraint violations handlers react to.
+const (
+	NotNullViolation    = "23502"
+	ForeignKeyViolation = "23503"
+	UniqueViolation     = "23505"
+	CheckViolation      = "23514"
+)
+
+func is(err error, code string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == code
+}
+
+// IsForeignKey reports a row that points at a missing parent, such as a
+// transaction for a spender that does not exist.
+func IsForeignKey(err error) bool {
+	return is(err, ForeignKeyViolation)
+}
+
+// IsUnique reports a duplicate of a unique column, such as an email that is
+// already registered.
+func IsUnique(err error) bool {
+	return is(err, UniqueViolation)
+}
+
+// IsInvalid reports a value rejected by a NOT NULL or CHECK constraint.
+func IsInvalid(err error) bool {
+	return is(err, NotNullViolation) || is(err, CheckViolation)
+}
@@ -0,0 +1,23 @@
+package dberr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	fk := &pq.Error{Code: ForeignKeyViolation}
+	unique := fmt.Errorf("insert: %w", &pq.Error{Code: UniqueViolation})
+
+	assert.True(t, IsForeignKey(fk))
+	assert.False(t, IsUnique(fk))
+	assert.True(t, IsUnique(unique))
+	assert.True(t, IsInvalid(&pq.Error{Code: NotNullViolation}))
+	assert.True(t, IsInvalid(&pq.Error{Code: CheckViolation}))
+	assert.False(t, IsInvalid(errors.New("connection refused")))
+	assert.False(t, IsForeignKey(nil))
+}
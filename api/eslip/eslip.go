@@ -1,16 +1,108 @@
 package eslip
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/slipjob"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/storage"
+	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
-func Upload(c echo.Context) error {
+// keyPrefix is where slips live in the store.
+const keyPrefix = "slips"
+
+const (
+	insertStmt = `INSERT INTO slip (spender_id, transaction_id, storage_key, location, filename, content_type, size)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+	ownsTransactionStmt = `SELECT EXISTS (SELECT 1 FROM transaction WHERE id = $1 AND spender_id = $2 AND deleted_at IS NULL);`
+	byTransactionStmt   = `SELECT id, spender_id, transaction_id, location, filename, content_type, size, created_at FROM slip
+WHERE transaction_id = $1 AND spender_id = $2 ORDER BY id;`
+)
+
+var errTransactionNotFound = errors.New("transaction not found")
+
+// Slip is a stored e-slip image, optionally attached to a transaction.
+type Slip struct {
+	ID            int       `json:"id"`
+	SpenderID     int64     `json:"spender_id"`
+	TransactionID *int64    `json:"transaction_id"`
+	Location      string    `json:"location"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// file reports the upload of one image. Error is empty when it was stored.
+// JobID is set for a slip sent without a transaction_id, which is queued to
+// be read into a draft expense.
+type file struct {
+	ID          int    `json:"id,omitempty"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+	Location    string `json:"location,omitempty"`
+	JobID       int    `json:"job_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type handler struct {
+	cfg   config.Upload
+	db    *sql.DB
+	store storage.Storage
+	// maxAttempts is how often the job of a slip is tried before it fails.
+	maxAttempts int
+}
+
+func New(cfg config.Upload, db *sql.DB, store storage.Storage, maxAttempts int) *handler {
+	return &handler{cfg, db, store, maxAttempts}
+}
+
+// ownsTransaction reports whether the live transaction id belongs to the
+// spender.
+func (h *handler) ownsTransaction(ctx context.Context, id, spenderID int64) (bool, error) {
+	var ok bool
+	err := h.db.QueryRowContext(ctx, ownsTransactionStmt, id, spenderID).Scan(&ok)
+	return ok, err
+}
+
+// Upload stores every image of the form field "images" as a slip of the
+// authenticated spender, attached to the transaction in the optional field
+// "transaction_id", which must be one of theirs. Without a transaction, each
+// slip is queued for the slip workers, which turn it into a draft expense;
+// the job can be followed at /spenders/:id/slip-jobs/:jobId. Each image is checked and
+// stored on its own, so one bad file does not fail the others: the response
+// is 200 when all were stored, 207 when only some were and 422 when none
+// were, with the outcome of each file under "files".
+func (h *handler) Upload(c echo.Context) error {
+	logger := mlog.L(c)
+	req := c.Request()
+	sp, ok := auth.Current(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+	}
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.cfg.MaxRequestSize)
+
 	form, err := c.MultipartForm()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"message": fmt.Sprintf("Request must not exceed %d bytes", h.cfg.MaxRequestSize),
+		})
+	}
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"message": "Failed to parse form",
@@ -18,55 +110,166 @@ func Upload(c echo.Context) error {
 		})
 	}
 	images := form.File["images"]
-	var locations []string
-	for _, image := range images {
-		fmt.Printf("Uploading file: %+v\n", image.Filename)
-		src, err := image.Open()
+	if len(images) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"message": "No images in form field images",
+		})
+	}
+
+	var transactionID *int64
+	if raw := c.FormValue("transaction_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"message": "Failed to parse form",
-				"error":   err.Error(),
-			})
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "transaction_id must be an integer"})
 		}
-		defer src.Close()
+		owns, err := h.ownsTransaction(req.Context(), id, sp.ID)
+		if err != nil {
+			logger.Error("query error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "query error"})
+		}
+		if !owns {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": errTransactionNotFound.Error()})
+		}
+		transactionID = &id
+	}
 
-		// upload to AWS S3 bucket
-		loc, err := UploadToS3(c, image.Filename, src)
+	files := make([]file, len(images))
+	var locations []string
+	for i, image := range images {
+		var err error
+		files[i], err = h.upload(req.Context(), Slip{SpenderID: sp.ID, TransactionID: transactionID}, image)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"message": "Failed to upload image",
-				"error":   err.Error(),
-			})
+			logger.Error("upload error", zap.String("filename", files[i].Filename), zap.Error(err))
+		}
+		if files[i].Error != "" {
+			continue
 		}
-		locations = append(locations, loc)
+		locations = append(locations, files[i].Location)
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"message":   "Image uploaded successfully",
+	status, message := http.StatusOK, "Image uploaded successfully"
+	switch len(locations) {
+	case 0:
+		status, message = http.StatusUnprocessableEntity, "No image could be uploaded"
+	case len(images):
+	default:
+		status, message = http.StatusMultiStatus, "Some images could not be uploaded"
+	}
+	return c.JSON(status, map[string]any{
+		"message":   message,
 		"locations": strings.Join(locations, ","),
+		"files":     files,
 	})
 }
 
-func UploadToS3(c echo.Context, filename string, src multipart.File) (string, error) {
-	// Assume that the file is uploaded to S3 bucket successfully
-	return "location/on/s3/bucket/" + filename, nil
-	// Create an AWS session
-	// sess, err := session.NewSession(&aws.Config{
-	// 	Region: aws.String(os.Getenv("AWS_REGION")),
-	// })
-	// if err != nil {
-	// 	return "", err
-	// }
-
-	// Upload the file to S3
-	// result, err = s3.New(sess).PutObject(&s3.PutObjectInput{
-	// 	Bucket: aws.String(os.Getenv("AWS_BUCKET")),
-	// 	Key:    aws.String(filename),
-	// 	Body:   src,
-	// })
-	// if err != nil {
-	// 	return "", err
-	// }
-
-	// return result.Location, nil
+// upload checks an image, stores it under a key made from its content, never
+// from the client's file name, and records it as slip, queueing its job when
+// it is not attached to a transaction. A rejected file has
+// its reason in Error; the returned error is only for failures the client
+// cannot fix.
+func (h *handler) upload(ctx context.Context, slip Slip, image *multipart.FileHeader) (file, error) {
+	f := file{Filename: sanitizeFilename(image.Filename), Size: image.Size}
+	if image.Size > h.cfg.MaxFileSize {
+		f.Error = fmt.Sprintf("file must not exceed %d bytes", h.cfg.MaxFileSize)
+		return f, nil
+	}
+
+	src, err := image.Open()
+	if err != nil {
+		f.Error = "file could not be read"
+		return f, err
+	}
+	defer src.Close()
+
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(src, head)
+	if f.ContentType, err = sniff(head[:n]); err != nil {
+		f.Error = err.Error()
+		return f, nil
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		f.Error = "file could not be read"
+		return f, err
+	}
+	key, err := storage.Key(keyPrefix, src, extensions[f.ContentType])
+	if err == nil {
+		_, err = src.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		f.Location, err = h.store.Put(ctx, key, src, image.Size, f.ContentType)
+	}
+	if err == nil {
+		f.ID, f.JobID, err = h.record(ctx, slip, key, f)
+	}
+	if err != nil {
+		f.Location = ""
+		f.Error = "file could not be stored"
+	}
+	return f, err
+}
+
+// record inserts the slip and, for a slip without a transaction, its job in
+// one database transaction.
+func (h *handler) record(ctx context.Context, slip Slip, key string, f file) (id, jobID int, err error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, insertStmt, slip.SpenderID, slip.TransactionID, key, f.Location,
+		f.Filename, f.ContentType, f.Size).Scan(&id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if slip.TransactionID == nil {
+		if jobID, err = slipjob.Enqueue(ctx, tx, id, h.maxAttempts); err != nil {
+			return 0, 0, err
+		}
+	}
+	return id, jobID, tx.Commit()
+}
+
+// GetByTransaction lists the slips attached to one of the spender's
+// transactions, oldest first.
+func (h *handler) GetByTransaction(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+	spenderID, err := strconv.ParseInt(c.Param("spenderId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "invalid spender id"})
+	}
+	transID, err := strconv.ParseInt(c.Param("transId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "invalid transaction id"})
+	}
+
+	owns, err := h.ownsTransaction(ctx, transID, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "query error"})
+	}
+	if !owns {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": errTransactionNotFound.Error()})
+	}
+
+	rows, err := h.db.QueryContext(ctx, byTransactionStmt, transID, spenderID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "query error"})
+	}
+	defer rows.Close()
+
+	slips := []Slip{}
+	for rows.Next() {
+		var s Slip
+		err := rows.Scan(&s.ID, &s.SpenderID, &s.TransactionID, &s.Location, &s.Filename, &s.ContentType, &s.Size, &s.CreatedAt)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "scan error"})
+		}
+		slips = append(slips, s)
+	}
+	return c.JSON(http.StatusOK, slips)
 }
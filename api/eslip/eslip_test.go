@@ -1,32 +1,257 @@
 package eslip
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/storage"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestUploadToS3(t *testing.T) {
-	t.Run("should able to upload image to S3 bucket", func(t *testing.T) {
-		f, _ := os.CreateTemp("", "eslip1.jpg")
-		defer f.Close()
-		defer os.Remove(f.Name())
+const png = "\x89PNG\r\n\x1a\nrest of the image"
 
+var limits = config.Upload{MaxFileSize: 64, MaxRequestSize: 4096}
+
+type upload struct {
+	name    string
+	content string
+}
+
+func setupUpload(transactionID string, files ...upload) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if transactionID != "" {
+		w.WriteField("transaction_id", transactionID)
+	}
+	for _, f := range files {
+		part, _ := w.CreateFormFile("images", f.name)
+		part.Write([]byte(f.content))
+	}
+	w.Close()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	auth.Set(c, auth.Spender{ID: 1, Role: auth.RoleSpender})
+	return c, rec
+}
+
+func newMock() (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	return db, mock
+}
+
+type uploadResponse struct {
+	Message   string `json:"message"`
+	Locations string `json:"locations"`
+	Files     []file `json:"files"`
+}
+
+func decode(rec *httptest.ResponseRecorder) uploadResponse {
+	var res uploadResponse
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return res
+}
+
+func TestUpload(t *testing.T) {
+	t.Run("should store the image under a key made from its content and queue its job", func(t *testing.T) {
+		store := storage.NewLocal(t.TempDir(), "https://files.example.com")
+		c, rec := setupUpload("", upload{"../../eslip1.gif", png})
+		db, mock := newMock()
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStmt).
+			WithArgs(1, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), "eslip1.gif", "image/png", len(png)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectQuery(`INSERT INTO slip_job (slip_id, max_attempts) VALUES ($1, $2) RETURNING id;`).
+			WithArgs(7, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectCommit()
+
+		err := New(limits, db, store, 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		res := decode(rec)
+		assert.Equal(t, "Image uploaded successfully", res.Message)
+		assert.Len(t, res.Files, 1)
+		f := res.Files[0]
+		assert.Equal(t, 7, f.ID)
+		assert.Equal(t, 4, f.JobID)
+		assert.Equal(t, "eslip1.gif", f.Filename)
+		assert.Equal(t, "image/png", f.ContentType)
+		assert.Equal(t, res.Locations, f.Location)
+		assert.True(t, strings.HasPrefix(f.Location, "https://files.example.com/slips/"))
+		assert.True(t, strings.HasSuffix(f.Location, ".png"))
+
+		rc, err := store.Get(context.Background(), strings.TrimPrefix(f.Location, "https://files.example.com/"))
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, png, string(b))
+	})
+
+	t.Run("should report each rejected file and keep the others", func(t *testing.T) {
+		c, rec := setupUpload("12",
+			upload{"slip.png", png},
+			upload{"script.png", "<script>alert(1)</script>"},
+			upload{"big.pdf", "%PDF-" + strings.Repeat("x", 64)},
+		)
+		db, mock := newMock()
+		defer db.Close()
+		mock.ExpectQuery(ownsTransactionStmt).WithArgs(12, 1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStmt).
+			WithArgs(1, 12, sqlmock.AnyArg(), sqlmock.AnyArg(), "slip.png", "image/png", len(png)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectCommit()
+
+		err := New(limits, db, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		res := decode(rec)
+		assert.NotEmpty(t, res.Files[0].Location)
+		assert.Equal(t, file{Filename: "script.png", Size: 25, Error: ErrUnsupportedType.Error()}, res.Files[1])
+		assert.Equal(t, file{Filename: "big.pdf", Size: 69, Error: "file must not exceed 64 bytes"}, res.Files[2])
+	})
+
+	t.Run("should fail when no file could be stored", func(t *testing.T) {
+		c, rec := setupUpload("", upload{"notes.txt", "hello"})
+
+		err := New(limits, nil, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, "", decode(rec).Locations)
+	})
+
+	t.Run("should refuse a request over the size limit", func(t *testing.T) {
+		c, rec := setupUpload("", upload{"a.png", png + strings.Repeat("x", 5000)})
+
+		err := New(limits, nil, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("should fail when there are no images", func(t *testing.T) {
+		c, rec := setupUpload("")
+
+		err := New(limits, nil, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should fail when the form is not multipart", func(t *testing.T) {
 		e := echo.New()
-		defer e.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		auth.Set(c, auth.Spender{ID: 1, Role: auth.RoleSpender})
+
+		err := New(limits, nil, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should not attach to someone else's transaction", func(t *testing.T) {
+		c, rec := setupUpload("99", upload{"slip.png", png})
+		db, mock := newMock()
+		defer db.Close()
+		mock.ExpectQuery(ownsTransactionStmt).WithArgs(99, 1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		err := New(limits, db, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should report a file whose slip could not be recorded", func(t *testing.T) {
+		c, rec := setupUpload("", upload{"slip.png", png})
+		db, mock := newMock()
+		defer db.Close()
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStmt).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
 
-		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		err := New(limits, db, storage.NewLocal(t.TempDir(), ""), 3).Upload(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, file{Filename: "slip.png", Size: int64(len(png)), ContentType: "image/png", Error: "file could not be stored"}, decode(rec).Files[0])
+	})
+}
+
+func TestGetByTransaction(t *testing.T) {
+	setup := func(spenderID, transID string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
+		c.SetParamNames("spenderId", "transId")
+		c.SetParamValues(spenderID, transID)
+		return c, rec
+	}
+
+	t.Run("should list the slips of a transaction", func(t *testing.T) {
+		c, rec := setup("1", "12")
+		db, mock := newMock()
+		defer db.Close()
+		created := time.Date(2024, 5, 18, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery(ownsTransactionStmt).WithArgs(12, 1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(byTransactionStmt).WithArgs(12, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "spender_id", "transaction_id", "location", "filename", "content_type", "size", "created_at"}).
+				AddRow(7, 1, 12, "https://files.example.com/slips/a.png", "front.png", "image/png", 100, created).
+				AddRow(8, 1, 12, "https://files.example.com/slips/b.pdf", "back.pdf", "application/pdf", 200, created))
+
+		err := New(limits, db, nil, 3).GetByTransaction(c)
 
-		loc, err := UploadToS3(c, "eslip1.jpg", f)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[
+{"id":7,"spender_id":1,"transaction_id":12,"location":"https://files.example.com/slips/a.png","filename":"front.png","content_type":"image/png","size":100,"created_at":"2024-05-18T12:00:00Z"},
+{"id":8,"spender_id":1,"transaction_id":12,"location":"https://files.example.com/slips/b.pdf","filename":"back.pdf","content_type":"application/pdf","size":200,"created_at":"2024-05-18T12:00:00Z"}]`, rec.Body.String())
+	})
+
+	t.Run("should return 404 for a missing transaction", func(t *testing.T) {
+		c, rec := setup("1", "13")
+		db, mock := newMock()
+		defer db.Close()
+		mock.ExpectQuery(ownsTransactionStmt).WithArgs(13, 1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		err := New(limits, db, nil, 3).GetByTransaction(c)
 
 		assert.NoError(t, err)
-		assert.Equal(t, "location/on/s3/bucket/eslip1.jpg", loc)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
 	})
 
+	t.Run("should reject a bad transaction id", func(t *testing.T) {
+		c, rec := setup("1", "abc")
+
+		err := New(limits, nil, nil, 3).GetByTransaction(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
 }
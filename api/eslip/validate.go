@@ -0,0 +1,78 @@
+package eslip
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	mimePNG  = "image/png"
+	mimeJPEG = "image/jpeg"
+	mimeHEIC = "image/heic"
+	mimePDF  = "application/pdf"
+
+	// sniffLen is how many leading bytes sniff needs.
+	sniffLen = 16
+
+	maxFilenameLen = 100
+)
+
+var ErrUnsupportedType = errors.New("file must be a PNG, JPEG, HEIC or PDF")
+
+// extensions are the extensions stored objects get for each accepted type.
+var extensions = map[string]string{
+	mimePNG:  ".png",
+	mimeJPEG: ".jpg",
+	mimeHEIC: ".heic",
+	mimePDF:  ".pdf",
+}
+
+// heicBrands are the ISO base media file brands of HEIC and HEIF images.
+var heicBrands = []string{"heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1"}
+
+// sniff returns the content type of a file from its leading bytes. The type
+// the client claims and the file name are ignored, as both are easy to fake.
+func sniff(head []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return mimePNG, nil
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return mimeJPEG, nil
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return mimePDF, nil
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		for _, brand := range heicBrands {
+			if string(head[8:12]) == brand {
+				return mimeHEIC, nil
+			}
+		}
+	}
+	return "", ErrUnsupportedType
+}
+
+// sanitizeFilename reduces a client file name to a safe display name: the
+// last path element, with control and path characters replaced, no leading
+// dots and at most maxFilenameLen runes. It is never used as a storage key.
+func sanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if utf8.RuneCountInString(name) > maxFilenameLen {
+		name = string([]rune(name)[:maxFilenameLen])
+	}
+	if name == "" {
+		return "slip"
+	}
+	return name
+}
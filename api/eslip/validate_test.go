@@ -0,0 +1,47 @@
+package eslip
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		head string
+		want string
+	}{
+		{png, mimePNG},
+		{"\xff\xd8\xff\xe0\x00\x10JFIF", mimeJPEG},
+		{"%PDF-1.7\n", mimePDF},
+		{"\x00\x00\x00\x18ftypheic\x00\x00\x00\x00", mimeHEIC},
+		{"\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00", mimeHEIC},
+	}
+	for _, tc := range tests {
+		got, err := sniff([]byte(tc.head))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+
+	for _, head := range []string{"", "GIF89a", "\x00\x00\x00\x18ftypmp42", "<svg>"} {
+		_, err := sniff([]byte(head))
+		assert.ErrorIs(t, err, ErrUnsupportedType, head)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]string{
+		"slip.png":               "slip.png",
+		"../../etc/passwd":       "passwd",
+		`C:\Users\hong\slip.jpg`: "slip.jpg",
+		"..hidden":               "hidden",
+		"a\x00b\nc<d>.png":       "a_b_c_d_.png",
+		"สลิป.jpg":               "สลิป.jpg",
+		"/":                      "slip",
+		strings.Repeat("a", 200): strings.Repeat("a", maxFilenameLen),
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, sanitizeFilename(in), in)
+	}
+}
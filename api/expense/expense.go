@@ -1,13 +1,17 @@
 package expense
 
-import "time"
+import (
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+)
 
 type Expense struct {
-	Id        int       `json:"id"`
-	Date      time.Time `json:"date"`
-	Amount    float64   `json:"amount"`
-	Category  string    `json:"category"`
-	Note      string    `json:"note"`
-	ImageUrl  string    `json:"image_url"`
-	SpenderId int       `json:"spender_id"`
+	Id        int          `json:"id"`
+	Date      time.Time    `json:"date"`
+	Amount    money.Amount `json:"amount"`
+	Category  string       `json:"category"`
+	Note      string       `json:"note"`
+	ImageUrl  string       `json:"image_url"`
+	SpenderId int          `json:"spender_id"`
 }
@@ -0,0 +1,37 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSV writes a ledger as CSV with a header row that the statement import
+// reads back with its default mapping.
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Write(r Row) error {
+	if !cw.wroteHeader {
+		if err := cw.w.Write(header); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+	return cw.w.Write(r.cells())
+}
+
+func (cw *csvWriter) Close() error {
+	if !cw.wroteHeader {
+		if err := cw.w.Write(header); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
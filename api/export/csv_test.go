@@ -0,0 +1,56 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func lunch() Row {
+	return Row{ID: 1, Date: time.Date(2024, 5, 18, 12, 0, 0, 0, time.UTC), Type: txtype.Expense,
+		Category: "Food", Note: "Lunch, with \"team\"", Amount: money.MustParse("120.5"), Currency: "THB"}
+}
+
+func TestCSV(t *testing.T) {
+	t.Run("rows under a header", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewCSV(&buf)
+
+		assert.NoError(t, w.Write(lunch()))
+		assert.NoError(t, w.Close())
+
+		assert.Equal(t, "date,transaction_type,category,note,amount,currency\n"+
+			"2024-05-18,expense,Food,\"Lunch, with \"\"team\"\"\",120.50,THB\n", buf.String())
+	})
+
+	t.Run("formulas are written as text", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewCSV(&buf)
+		row := lunch()
+		row.Category, row.Note = "@SUM(A1)", "=HYPERLINK(\"http://x\")"
+
+		assert.NoError(t, w.Write(row))
+		assert.NoError(t, w.Close())
+
+		assert.Contains(t, buf.String(), "2024-05-18,expense,'@SUM(A1),\"'=HYPERLINK(\"\"http://x\"\")\",120.50,THB\n")
+	})
+
+	t.Run("no rows is just the header", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		assert.NoError(t, NewCSV(&buf).Close())
+
+		assert.Equal(t, "date,transaction_type,category,note,amount,currency\n", buf.String())
+	})
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("docx", &bytes.Buffer{}, Statement{})
+
+	assert.ErrorIs(t, err, ErrFormat)
+	assert.Equal(t, "application/pdf", ContentType(FormatPDF))
+}
@@ -0,0 +1,117 @@
+// Package export writes transaction ledgers as CSV, XLSX and PDF statements.
+// Every writer streams: rows go to the underlying writer as they come, and
+// only a bounded amount (one PDF page) is ever held in memory.
+package export
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+)
+
+var (
+	ErrFormat      = errors.New("format must be one of csv, xlsx or pdf")
+	ErrUnencodable = errors.New("pdf statements only print Latin-1 text, export csv or xlsx instead")
+)
+
+// header names the columns of every format, in the order of Row.fields.
+var header = []string{"date", "transaction_type", "category", "note", "amount", "currency"}
+
+// amountColumn is the index of the amount in header.
+const amountColumn = 4
+
+// textColumns are the indexes in header of the text the spender typed in.
+var textColumns = []int{2, 3}
+
+// formulaPrefixes make a spreadsheet read a cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// Row is one ledger line.
+type Row struct {
+	ID       int
+	Date     time.Time
+	Type     txtype.TransactionType
+	Category string
+	Note     string
+	Amount   money.Amount
+	Currency string
+}
+
+func (r Row) fields() []string {
+	return []string{r.Date.Format(time.DateOnly), string(r.Type), r.Category, r.Note, r.Amount.String(), r.Currency}
+}
+
+// cells are the fields as written to a spreadsheet. A category or note that
+// a spreadsheet would run as a formula is prefixed with a quote so it is
+// shown as text.
+func (r Row) cells() []string {
+	f := r.fields()
+	for _, i := range textColumns {
+		f[i] = escapeFormula(f[i])
+	}
+	return f
+}
+
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// Totals are printed at the end of a PDF statement. They are in the spender's
+// base currency, whatever the currencies of the rows.
+type Totals struct {
+	Income   money.Amount
+	Expenses money.Amount
+	Balance  money.Amount
+}
+
+// Statement describes the ledger being exported.
+type Statement struct {
+	Title  string
+	Totals Totals
+}
+
+// Writer writes the rows of a ledger. Close finishes the file; it does not
+// close the underlying writer.
+type Writer interface {
+	Write(Row) error
+	Close() error
+}
+
+// ContentType returns the media type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return ""
+}
+
+// New returns a writer of the format. Only PDF statements use s.
+func New(format string, w io.Writer, s Statement) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSV(w), nil
+	case FormatXLSX:
+		return NewXLSX(w)
+	case FormatPDF:
+		return NewPDF(w, s), nil
+	}
+	return nil, ErrFormat
+}
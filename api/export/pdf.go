@@ -0,0 +1,214 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A4 portrait in points, written in 9pt Courier so columns line up without
+// font metrics.
+const (
+	pdfWidth    = 595
+	pdfHeight   = 842
+	pdfMargin   = 40
+	pdfFontSize = 9
+	pdfLeading  = 11
+	pdfLines    = (pdfHeight - 2*pdfMargin) / pdfLeading
+)
+
+// Objects 1 to 3 are fixed; pages and their contents follow from 4 on.
+const (
+	pdfCatalog = 1
+	pdfPages   = 2
+	pdfFont    = 3
+)
+
+// countingWriter remembers the first error and how many bytes were written,
+// which the cross-reference table needs.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...any) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) write(b []byte) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+type pdfWriter struct {
+	w       *countingWriter
+	s       Statement
+	offsets []int64
+	pages   []int
+	content bytes.Buffer
+	lines   int
+}
+
+// NewPDF writes a ledger as a PDF statement headed by s.Title on every page
+// and ending with s.Totals. Each page is written as soon as it is full.
+// Courier only covers Latin-1, so Write refuses rows with other characters
+// rather than misprint them.
+func NewPDF(w io.Writer, s Statement) Writer {
+	pw := &pdfWriter{w: &countingWriter{w: w}, s: s, offsets: make([]int64, pdfFont)}
+	pw.w.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	pw.begin(pdfCatalog)
+	pw.w.printf("<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pdfPages)
+	pw.begin(pdfFont)
+	pw.w.printf("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>\nendobj\n")
+	return pw
+}
+
+// begin starts object id, or a new object when id is 0, and returns its id.
+func (pw *pdfWriter) begin(id int) int {
+	if id == 0 {
+		pw.offsets = append(pw.offsets, 0)
+		id = len(pw.offsets)
+	}
+	pw.offsets[id-1] = pw.w.n
+	pw.w.printf("%d 0 obj\n", id)
+	return id
+}
+
+// line adds a line of text to the current page, starting a page first when
+// there is none.
+func (pw *pdfWriter) line(text string) {
+	if pw.lines == 0 {
+		pw.content.Reset()
+		fmt.Fprintf(&pw.content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfHeight-pdfMargin)
+		pw.text(fmt.Sprintf("%s    page %d", pw.s.Title, len(pw.pages)+1))
+		pw.text("")
+		pw.text(columns(header[0], header[1], header[2], header[3], header[4], header[5]))
+		pw.text(strings.Repeat("-", 95))
+	}
+	pw.text(text)
+	if pw.lines >= pdfLines {
+		pw.flushPage()
+	}
+}
+
+func (pw *pdfWriter) text(s string) {
+	pw.content.WriteByte('(')
+	pw.content.Write(pdfString(s))
+	pw.content.WriteString(") '\n")
+	pw.lines++
+}
+
+func (pw *pdfWriter) flushPage() {
+	pw.content.WriteString("ET")
+	contents := pw.begin(0)
+	pw.w.printf("<< /Length %d >>\nstream\n", pw.content.Len())
+	pw.w.write(pw.content.Bytes())
+	pw.w.printf("\nendstream\nendobj\n")
+
+	page := pw.begin(0)
+	pw.w.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+		pdfPages, pdfWidth, pdfHeight, pdfFont, contents)
+	pw.pages = append(pw.pages, page)
+	pw.lines = 0
+}
+
+// Write returns ErrUnencodable, writing nothing, when the row has characters
+// outside Latin-1.
+func (pw *pdfWriter) Write(r Row) error {
+	f := r.fields()
+	for _, v := range f {
+		if !latin1(v) {
+			return ErrUnencodable
+		}
+	}
+	pw.line(columns(f[0], f[1], f[2], f[3], f[4], f[5]))
+	return pw.w.err
+}
+
+func (pw *pdfWriter) Close() error {
+	totals := []string{
+		"",
+		"Totals in base currency",
+		fmt.Sprintf("  Income   %14s", pw.s.Totals.Income),
+		fmt.Sprintf("  Expenses %14s", pw.s.Totals.Expenses),
+		fmt.Sprintf("  Balance  %14s", pw.s.Totals.Balance),
+	}
+	if pw.lines > 0 && pw.lines+len(totals) > pdfLines {
+		pw.flushPage()
+	}
+	for _, t := range totals {
+		pw.line(t)
+	}
+	if pw.lines > 0 {
+		pw.flushPage()
+	}
+
+	pw.begin(pdfPages)
+	kids := make([]string, len(pw.pages))
+	for i, p := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", p)
+	}
+	pw.w.printf("<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(pw.pages))
+
+	xref := pw.w.n
+	pw.w.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, off := range pw.offsets {
+		pw.w.printf("%010d 00000 n \n", off)
+	}
+	pw.w.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, pdfCatalog, xref)
+	return pw.w.err
+}
+
+// columns lays out one ledger line in fixed-width columns, 95 characters in
+// all, cutting long categories and notes short.
+func columns(date, typ, category, note, amount, currency string) string {
+	return fmt.Sprintf("%-10s  %-7s  %-18s  %-35s %14s %-3s", date, typ, cut(category, 18), cut(note, 35), amount, currency)
+}
+
+func cut(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "~"
+}
+
+// latin1 reports whether a PDF statement can print s.
+func latin1(s string) bool {
+	for _, r := range s {
+		if r > 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// pdfString encodes Latin-1 text for a PDF literal string in WinAnsiEncoding,
+// which agrees with Latin-1 for printable characters. Tabs, line breaks and
+// other control characters cannot be printed on a line, so they become
+// spaces.
+func pdfString(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r >= 0x20 && r < 0x7f || r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		default:
+			b = append(b, ' ')
+		}
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertXref checks that every cross-reference entry points at its object.
+func assertXref(t *testing.T, pdf []byte) {
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	assert.NotNil(t, m)
+	start, _ := strconv.Atoi(string(m[1]))
+	entries := strings.Split(string(pdf[start:]), "\n")[3:]
+	for i, e := range entries {
+		if !strings.HasSuffix(e, " n ") {
+			break
+		}
+		off, _ := strconv.Atoi(e[:10])
+		assert.True(t, bytes.HasPrefix(pdf[off:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	t.Run("statement with totals", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewPDF(&buf, Statement{
+			Title:  "Statement of spender 1",
+			Totals: Totals{Income: money.MustParse("1000"), Expenses: money.MustParse("120.5"), Balance: money.MustParse("879.5")},
+		})
+		row := lunch()
+		row.Note = "(rice)\t\\ café"
+		assert.NoError(t, w.Write(row))
+		assert.NoError(t, w.Close())
+
+		pdf := buf.String()
+		assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+		assert.Contains(t, pdf, "/Count 1 >>")
+		assert.Contains(t, pdf, "(Statement of spender 1    page 1) '")
+		assert.Contains(t, pdf, `\(rice\) \\ caf`+"\xe9")
+		assert.Contains(t, pdf, "(  Expenses         120.50) '")
+		assert.Contains(t, pdf, "(  Balance          879.50) '")
+		assertXref(t, buf.Bytes())
+	})
+
+	t.Run("rows beyond Latin-1 are refused", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewPDF(&buf, Statement{Title: "Statement"})
+		row := lunch()
+		row.Note = "ข้าว"
+		before := buf.Len()
+
+		err := w.Write(row)
+
+		assert.ErrorIs(t, err, ErrUnencodable)
+		assert.Equal(t, before, buf.Len())
+		assert.NoError(t, w.Close())
+		assert.NotContains(t, buf.String(), "?")
+		assertXref(t, buf.Bytes())
+	})
+
+	t.Run("long ledgers span pages", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewPDF(&buf, Statement{Title: "Statement"})
+		for i := 0; i < 200; i++ {
+			assert.NoError(t, w.Write(lunch()))
+		}
+		assert.NoError(t, w.Close())
+
+		assert.Contains(t, buf.String(), "/Count 4 >>")
+		assertXref(t, buf.Bytes())
+	})
+}
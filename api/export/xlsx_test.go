@@ -0,0 +1,40 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf)
+	assert.NoError(t, err)
+	row := lunch()
+	row.Category = "-1+1"
+	row.Note = "<b>Lunch</b> & tea"
+	assert.NoError(t, w.Write(row))
+	assert.NoError(t, w.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	var names []string
+	var sheet string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			b, _ := io.ReadAll(rc)
+			sheet = string(b)
+		}
+	}
+
+	assert.Equal(t, []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"}, names)
+	assert.Contains(t, sheet, `<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">date</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="C2" t="inlineStr"><is><t xml:space="preserve">&#39;-1+1</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="D2" t="inlineStr"><is><t xml:space="preserve">&lt;b&gt;Lunch&lt;/b&gt; &amp; tea</t></is></c><c r="E2"><v>120.50</v></c>`)
+	assert.Contains(t, sheet, `</sheetData></worksheet>`)
+}
@@ -1,11 +1,27 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
+
 	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
-func AuthCheck(username, password string, c echo.Context) (bool, error) {
-	isPass := auth.Check(username, password)
-	return isPass, nil
+// AuthCheck validates BasicAuth credentials (email and password) against the
+// spender table and stores the authenticated spender in the context.
+func AuthCheck(db *sql.DB) middleware.BasicAuthValidator {
+	return func(username, password string, c echo.Context) (bool, error) {
+		sp, err := auth.Check(c.Request().Context(), db, username, password)
+		if errors.Is(err, auth.ErrInvalidCredential) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		auth.Set(c, sp)
+		return true, nil
+	}
 }
@@ -6,24 +6,38 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestAuthMiddleware(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+
 	tests := []struct {
 		auth           string
 		wantStatusCode int
 	}{
-		{"user:secret", http.StatusOK},
-		{"user:wrong-secret", http.StatusUnauthorized},
+		{"hong@jot.ok:secret-password", http.StatusOK},
+		{"hong@jot.ok:wrong-secret", http.StatusUnauthorized},
 	}
 
 	for _, tc := range tests {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		rows := sqlmock.NewRows([]string{"id", "name", "email", "role", "token_version", "password_hash"}).
+			AddRow(1, "HongJot", "hong@jot.ok", "spender", 0, string(hash))
+		mock.ExpectQuery(`SELECT id, name, email, role, token_version, password_hash FROM spender WHERE email = $1 AND deleted_at IS NULL;`).
+			WithArgs("hong@jot.ok").WillReturnRows(rows)
+
 		e := echo.New()
-		e.Use(middleware.BasicAuth(AuthCheck))
-		e.GET("/", func(c echo.Context) error { return c.String(http.StatusOK, "[]") })
+		e.Use(middleware.BasicAuth(AuthCheck(db)))
+		e.GET("/", func(c echo.Context) error {
+			sp, _ := auth.Current(c)
+			return c.JSON(http.StatusOK, sp)
+		})
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		auth := "basic " + base64.StdEncoding.EncodeToString([]byte(tc.auth))
 		req.Header.Set(echo.HeaderAuthorization, auth)
@@ -32,5 +46,9 @@ func TestAuthMiddleware(t *testing.T) {
 		e.ServeHTTP(rec, req)
 
 		assert.Equal(t, tc.wantStatusCode, rec.Code)
+		if tc.wantStatusCode == http.StatusOK {
+			assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok", "role": "spender"}`, rec.Body.String())
+		}
+		db.Close()
 	}
 }
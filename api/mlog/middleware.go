@@ -26,6 +26,16 @@ func logParentID(c echo.Context, logger *zap.Logger) *zap.Logger {
 		xParent = uuid.NewString()
 	}
 	xSpan := uuid.NewString()
+	c.Set(parentKey, xParent)
+	c.Set(spanKey, xSpan)
 	return logger.With(zap.String("parent-id", xParent),
 		zap.String("span-id", xSpan))
 }
+
+// IDs returns the parent-id and span-id the middleware logs the request
+// with, or empty strings when the middleware did not run.
+func IDs(c echo.Context) (parent, span string) {
+	parent, _ = c.Get(parentKey).(string)
+	span, _ = c.Get(spanKey).(string)
+	return parent, span
+}
@@ -36,3 +36,21 @@ func TestUnsetLogMiddleware(t *testing.T) {
 
 	assert.IsType(t, &zap.Logger{}, L(ctx))
 }
+
+func TestLogMiddlewareIDs(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware(zap.NewNop()))
+	var parent, span string
+	e.GET("/", func(c echo.Context) error {
+		parent, span = IDs(c)
+		return nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Parent-ID", "parent-1")
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, "parent-1", parent)
+	assert.NotEmpty(t, span)
+}
@@ -5,7 +5,11 @@ import (
 	"go.uber.org/zap"
 )
 
-const key = "logger"
+const (
+	key       = "logger"
+	parentKey = "parent-id"
+	spanKey   = "span-id"
+)
 
 func L(c echo.Context) *zap.Logger {
 	switch logger := c.Get(key).(type) {
@@ -0,0 +1,182 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// scale is the number of minor units in one major unit.
+const scale = 100
+
+var (
+	ErrSyntax    = errors.New("amount must be a decimal number")
+	ErrPrecision = errors.New("amount must not have more than 2 decimal places")
+	ErrRange     = errors.New("amount is out of range")
+)
+
+// Amount is a sum of money held exactly as a whole number of minor units,
+// matching the DECIMAL(10,2) amount columns, so 12.34 is Amount(1234).
+type Amount int64
+
+// Parse reads a plain decimal such as "12", "-3.5" or "0.01". Exponents are
+// rejected and so are amounts with more than two fractional digits, rather
+// than silently rounding them away.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return 0, ErrSyntax
+	}
+	if len(frac) > 2 {
+		if strings.TrimRight(frac[2:], "0") != "" {
+			return 0, ErrPrecision
+		}
+		frac = frac[:2]
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, ErrRange
+	}
+	if neg {
+		n = -n
+	}
+	return Amount(n), nil
+}
+
+// MustParse is Parse for constants; it panics on a malformed amount.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromFloat rounds f to the nearest minor unit. It is only meant for values
+// that are already rounded to cents, such as numbers read back from a driver
+// that reports NUMERIC as float64.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * scale))
+}
+
+// Float64 returns a as a float, for ratios such as percentages.
+func (a Amount) Float64() float64 {
+	return float64(a) / scale
+}
+
+// Div divides a into n equal parts, rounding half away from zero.
+func (a Amount) Div(n int) Amount {
+	if n == 0 {
+		return 0
+	}
+	q, r := int64(a)/int64(n), int64(a)%int64(n)
+	if 2*abs(r) >= abs(int64(n)) {
+		if (r < 0) != (n < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return Amount(q)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (a Amount) String() string {
+	sign := ""
+	n := int64(a)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/scale, n%scale)
+}
+
+// MarshalJSON writes a as a JSON number with two decimals, such as 12.30.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	v, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Scan reads a NUMERIC column, which lib/pq returns as text.
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = Amount(v * scale)
+		return nil
+	case float64:
+		*a = FromFloat(v)
+		return nil
+	}
+	return fmt.Errorf("money: cannot scan %T", src)
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value writes a as a decimal string so the database stores it exactly.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Cause returns the money error that err wraps, such as ErrPrecision from a
+// request body that failed to bind, or nil when err is about something else.
+func Cause(err error) error {
+	for _, e := range []error{ErrPrecision, ErrSyntax, ErrRange} {
+		if errors.Is(err, e) {
+			return e
+		}
+	}
+	return nil
+}
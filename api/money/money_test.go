@@ -0,0 +1,95 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"12.3", 1230},
+		{"12.34", 1234},
+		{"12.340", 1234},
+		{".5", 50},
+		{"-3.05", -305},
+		{"99999999.99", 9999999999},
+	}
+	for _, tc := range tests {
+		got, err := Parse(tc.in)
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+
+	for in, want := range map[string]error{
+		"":       ErrSyntax,
+		"abc":    ErrSyntax,
+		"1e3":    ErrSyntax,
+		"1.2.3":  ErrSyntax,
+		"12.345": ErrPrecision,
+		"0.001":  ErrPrecision,
+	} {
+		_, err := Parse(in)
+		assert.ErrorIs(t, err, want, in)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0.00", Amount(0).String())
+	assert.Equal(t, "12.30", Amount(1230).String())
+	assert.Equal(t, "-0.05", Amount(-5).String())
+}
+
+func TestSumHasNoDrift(t *testing.T) {
+	var total Amount
+	for i := 0; i < 10; i++ {
+		total += MustParse("0.1")
+	}
+	assert.Equal(t, MustParse("1"), total)
+}
+
+func TestDiv(t *testing.T) {
+	assert.Equal(t, Amount(333), Amount(1000).Div(3))
+	assert.Equal(t, Amount(667), Amount(2000).Div(3))
+	assert.Equal(t, Amount(-667), Amount(-2000).Div(3))
+	assert.Equal(t, Amount(0), Amount(1000).Div(0))
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Amount Amount `json:"amount"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 66.6}`), &v))
+	assert.Equal(t, Amount(6660), v.Amount)
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "10.05"}`), &v))
+	assert.Equal(t, Amount(1005), v.Amount)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount": 1.005}`), &v), ErrPrecision)
+
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 10.05}`, string(b))
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+	assert.NoError(t, a.Scan([]byte("1234.50")))
+	assert.Equal(t, Amount(123450), a)
+	assert.NoError(t, a.Scan(int64(7)))
+	assert.Equal(t, Amount(700), a)
+	assert.NoError(t, a.Scan(70.6))
+	assert.Equal(t, Amount(7060), a)
+	assert.NoError(t, a.Scan(nil))
+	assert.Equal(t, Amount(0), a)
+	assert.Error(t, a.Scan(true))
+
+	v, err := MustParse("66.6").Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "66.60", v)
+}
@@ -0,0 +1,336 @@
+package recurring
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dberr"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrInvalidSpender    = errors.New("invalid spender")
+	ErrInvalidRecurrence = errors.New("invalid recurrence id")
+	ErrNotFound          = errors.New("recurrence not found")
+	ErrSpenderNotFound   = errors.New("spender not found")
+	ErrAmount            = errors.New("amount is lower than 0.0")
+	ErrCategory          = errors.New("category is required")
+	ErrFrequency         = errors.New("frequency must be one of daily, weekly, monthly or yearly")
+	ErrStartDate         = errors.New("start_date must be in YYYY-MM-DD format")
+	ErrEndDate           = errors.New("end_date must be in YYYY-MM-DD format and not before start_date")
+)
+
+const (
+	columns    = `id, spender_id, amount, category, transaction_type, note, frequency, start_date, end_date, last_run, paused`
+	listStmt   = `SELECT ` + columns + ` FROM recurring WHERE spender_id = $1 ORDER BY id;`
+	insertStmt = `INSERT INTO recurring (spender_id, amount, category, transaction_type, note, frequency, start_date, end_date)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;`
+	lockStmt   = `SELECT ` + columns + ` FROM recurring WHERE id = $1 AND spender_id = $2 FOR UPDATE;`
+	pauseStmt  = `UPDATE recurring SET paused = TRUE WHERE id = $1 AND spender_id = $2 RETURNING ` + columns
+	resumeStmt = `UPDATE recurring SET paused = FALSE, last_run = GREATEST(last_run, $1) WHERE id = $2 AND spender_id = $3 RETURNING ` + columns
+	deleteStmt = `DELETE FROM recurring WHERE id = $1 AND spender_id = $2 RETURNING ` + columns
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+// Recurrence is a template that the scheduler turns into one transaction per
+// date of its rule.
+type Recurrence struct {
+	ID              int
+	SpenderID       int
+	Amount          money.Amount
+	Category        string
+	TransactionType txtype.TransactionType
+	Note            string
+	Frequency       string
+	StartDate       time.Time
+	EndDate         *time.Time
+	LastRun         *time.Time
+	Paused          bool
+}
+
+type request struct {
+	Amount          money.Amount           `json:"amount"`
+	Category        string                 `json:"category"`
+	TransactionType txtype.TransactionType `json:"transaction_type"`
+	Note            string                 `json:"note"`
+	Frequency       string                 `json:"frequency"`
+	StartDate       string                 `json:"start_date"`
+	EndDate         string                 `json:"end_date"`
+}
+
+type response struct {
+	ID              int                    `json:"id"`
+	SpenderID       int                    `json:"spender_id"`
+	Amount          money.Amount           `json:"amount"`
+	Category        string                 `json:"category"`
+	TransactionType txtype.TransactionType `json:"transaction_type"`
+	Note            string                 `json:"note"`
+	Frequency       string                 `json:"frequency"`
+	StartDate       string                 `json:"start_date"`
+	EndDate         *string                `json:"end_date"`
+	LastRun         *string                `json:"last_run"`
+	Paused          bool                   `json:"paused"`
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(dateLayout)
+	return &s
+}
+
+func (r Recurrence) response() response {
+	return response{
+		ID:              r.ID,
+		SpenderID:       r.SpenderID,
+		Amount:          r.Amount,
+		Category:        r.Category,
+		TransactionType: r.TransactionType,
+		Note:            r.Note,
+		Frequency:       r.Frequency,
+		StartDate:       r.StartDate.Format(dateLayout),
+		EndDate:         formatDate(r.EndDate),
+		LastRun:         formatDate(r.LastRun),
+		Paused:          r.Paused,
+	}
+}
+
+func (req request) recurrence(spenderID int) (Recurrence, error) {
+	r := Recurrence{
+		SpenderID:       spenderID,
+		Amount:          req.Amount,
+		Category:        req.Category,
+		TransactionType: req.TransactionType,
+		Note:            req.Note,
+		Frequency:       req.Frequency,
+	}
+	if req.Amount < 0 {
+		return r, ErrAmount
+	}
+	if req.Category == "" {
+		return r, ErrCategory
+	}
+	if !req.TransactionType.Valid() {
+		return r, txtype.ErrInvalid
+	}
+	if !validFrequency(req.Frequency) {
+		return r, ErrFrequency
+	}
+	start, err := time.Parse(dateLayout, req.StartDate)
+	if err != nil {
+		return r, ErrStartDate
+	}
+	r.StartDate = start
+	if req.EndDate != "" {
+		end, err := time.Parse(dateLayout, req.EndDate)
+		if err != nil || end.Before(start) {
+			return r, ErrEndDate
+		}
+		r.EndDate = &end
+	}
+	return r, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecurrence(s scanner) (Recurrence, error) {
+	var r Recurrence
+	var end, lastRun sql.NullTime
+	err := s.Scan(&r.ID, &r.SpenderID, &r.Amount, &r.Category, &r.TransactionType, &r.Note,
+		&r.Frequency, &r.StartDate, &end, &lastRun, &r.Paused)
+	if end.Valid {
+		r.EndDate = &end.Time
+	}
+	if lastRun.Valid {
+		r.LastRun = &lastRun.Time
+	}
+	return r, err
+}
+
+type handler struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func New(db *sql.DB) *handler {
+	return &handler{db: db, now: time.Now}
+}
+
+func spenderID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, ErrInvalidSpender
+	}
+	return id, nil
+}
+
+func recurrenceID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("recurringId"))
+	if err != nil {
+		return 0, ErrInvalidRecurrence
+	}
+	return id, nil
+}
+
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	rows, err := h.db.QueryContext(ctx, listStmt, spID)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	defer rows.Close()
+
+	res := []response{}
+	for rows.Next() {
+		r, err := scanRecurrence(rows)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
+		}
+		res = append(res, r.response())
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h handler) Create(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	var req request
+	if err := c.Bind(&req); err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		message := "invalid request body"
+		if cause := money.Cause(err); cause != nil {
+			message = cause.Error()
+		}
+		return c.JSON(http.StatusBadRequest, Err{Message: message})
+	}
+	r, err := req.recurrence(spID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "insert recurrence error"})
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, insertStmt, r.SpenderID, r.Amount, r.Category, r.TransactionType,
+		r.Note, r.Frequency, r.StartDate, r.EndDate).Scan(&r.ID)
+	if dberr.IsForeignKey(err) {
+		return c.JSON(http.StatusNotFound, Err{Message: ErrSpenderNotFound.Error()})
+	}
+	if err == nil {
+		err = audit.Record(c, tx, audit.Entry{Action: audit.ActionCreate, Entity: audit.EntityRecurring, EntityID: int64(r.ID), After: r.response()})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logger.Error("insert recurrence error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "insert recurrence error"})
+	}
+
+	return c.JSON(http.StatusCreated, r.response())
+}
+
+// Pause stops the scheduler from creating transactions for a recurrence.
+func (h handler) Pause(c echo.Context) error {
+	return h.exec(c, audit.ActionUpdate, pauseStmt)
+}
+
+// Resume restarts a paused recurrence from today on. Dates missed while it
+// was paused are skipped rather than caught up.
+func (h handler) Resume(c echo.Context) error {
+	yesterday := h.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	return h.exec(c, audit.ActionUpdate, resumeStmt, yesterday)
+}
+
+// Delete removes a recurrence. Transactions it already created are kept and
+// lose their link to it.
+func (h handler) Delete(c echo.Context) error {
+	return h.exec(c, audit.ActionDelete, deleteStmt)
+}
+
+// exec changes a recurrence with stmt, which returns the row as it was left,
+// and records the change in the audit log in the same database transaction.
+// The recurrence is locked first so the entry holds its state before the
+// change.
+func (h handler) exec(c echo.Context, action, stmt string, args ...any) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	id, err := recurrenceID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "update recurrence error"})
+	}
+	defer tx.Rollback()
+
+	before, err := scanRecurrence(tx.QueryRowContext(ctx, lockStmt, id, spID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, Err{Message: ErrNotFound.Error()})
+	}
+	if err != nil {
+		logger.Error("update recurrence error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "update recurrence error"})
+	}
+
+	entry := audit.Entry{Action: action, Entity: audit.EntityRecurring, EntityID: int64(id), Before: before.response()}
+	after, err := scanRecurrence(tx.QueryRowContext(ctx, stmt, append(args, id, spID)...))
+	if err == nil && action != audit.ActionDelete {
+		entry.After = after.response()
+	}
+	if err == nil {
+		err = audit.Record(c, tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logger.Error("update recurrence error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "update recurrence error"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,203 @@
+package recurring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var recurrenceColumns = []string{"id", "spender_id", "amount", "category", "transaction_type", "note", "frequency", "start_date", "end_date", "last_run", "paused"}
+
+func newContext(method, body string, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names := []string{"id", "recurringId"}
+	c.SetParamNames(names[:len(params)]...)
+	c.SetParamValues(params...)
+	return c, rec
+}
+
+func TestGetAll(t *testing.T) {
+	c, rec := newContext(http.MethodGet, "", "1")
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	rows := sqlmock.NewRows(recurrenceColumns).
+		AddRow(1, 1, 15000, "Rent", "expense", "Condo", Monthly, date(2024, time.January, 1), nil, date(2024, time.May, 1), false)
+	mock.ExpectQuery(listStmt).WithArgs(1).WillReturnRows(rows)
+
+	err := New(db).GetAll(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"id":1,"spender_id":1,"amount":15000,"category":"Rent","transaction_type":"expense","note":"Condo",
+		"frequency":"monthly","start_date":"2024-01-01","end_date":null,"last_run":"2024-05-01","paused":false}]`, rec.Body.String())
+}
+
+func TestCreate(t *testing.T) {
+	t.Run("create recurrence successfully", func(t *testing.T) {
+		body := `{"amount":15000,"category":"Rent","transaction_type":"expense","frequency":"monthly","start_date":"2024-01-01","end_date":"2024-12-31"}`
+		c, rec := newContext(http.MethodPost, body, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		end := date(2024, time.December, 31)
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStmt).
+			WithArgs(1, money.MustParse("15000"), "Rent", "expense", "", Monthly, date(2024, time.January, 1), &end).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionCreate, audit.EntityRecurring, int64(3), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(db).Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.JSONEq(t, `{"id":3,"spender_id":1,"amount":15000,"category":"Rent","transaction_type":"expense","note":"",
+			"frequency":"monthly","start_date":"2024-01-01","end_date":"2024-12-31","last_run":null,"paused":false}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown spender", func(t *testing.T) {
+		body := `{"amount":100,"category":"Rent","transaction_type":"expense","frequency":"monthly","start_date":"2024-01-01"}`
+		c, rec := newContext(http.MethodPost, body, "99")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStmt).WillReturnError(&pq.Error{Code: "23503"})
+		mock.ExpectRollback()
+
+		err := New(db).Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		tests := []struct {
+			body string
+			want string
+		}{
+			{`{"amount":-1,"category":"Rent","transaction_type":"expense","frequency":"monthly","start_date":"2024-01-01"}`, "amount is lower than 0.0"},
+			{`{"amount":1,"transaction_type":"expense","frequency":"monthly","start_date":"2024-01-01"}`, "category is required"},
+			{`{"amount":1,"category":"Rent","transaction_type":"transfer","frequency":"monthly","start_date":"2024-01-01"}`, "invalid transaction type"},
+			{`{"amount":1,"category":"Rent","transaction_type":"expense","frequency":"hourly","start_date":"2024-01-01"}`, "frequency must be one of daily, weekly, monthly or yearly"},
+			{`{"amount":1,"category":"Rent","transaction_type":"expense","frequency":"monthly"}`, "start_date must be in YYYY-MM-DD format"},
+			{`{"amount":1,"category":"Rent","transaction_type":"expense","frequency":"monthly","start_date":"2024-01-01","end_date":"2023-01-01"}`, "end_date must be in YYYY-MM-DD format and not before start_date"},
+		}
+		for _, tc := range tests {
+			c, rec := newContext(http.MethodPost, tc.body, "1")
+
+			err := New(nil).Create(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, `{"message":"`+tc.want+`"}`, rec.Body.String())
+		}
+	})
+}
+
+func rent(paused bool, lastRun any) *sqlmock.Rows {
+	return sqlmock.NewRows(recurrenceColumns).
+		AddRow(3, 1, 15000, "Rent", "expense", "Condo", Monthly, date(2024, time.January, 1), nil, lastRun, paused)
+}
+
+func TestPauseResumeDelete(t *testing.T) {
+	t.Run("pause recurrence", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "", "1", "3")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs(3, 1).WillReturnRows(rent(false, date(2024, time.May, 1)))
+		mock.ExpectQuery(pauseStmt).WithArgs(3, 1).WillReturnRows(rent(true, date(2024, time.May, 1)))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionUpdate, audit.EntityRecurring, int64(3), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(db).Pause(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("resume skips the dates missed while paused", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "", "1", "3")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs(3, 1).WillReturnRows(rent(true, date(2024, time.March, 1)))
+		mock.ExpectQuery(resumeStmt).WithArgs(date(2024, time.May, 17), 3, 1).WillReturnRows(rent(false, date(2024, time.May, 17)))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionUpdate, audit.EntityRecurring, int64(3), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		h := New(db)
+		h.now = func() time.Time { return time.Date(2024, time.May, 18, 9, 30, 0, 0, time.UTC) }
+		err := h.Resume(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete recurrence", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "1", "3")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs(3, 1).WillReturnRows(rent(false, nil))
+		mock.ExpectQuery(deleteStmt).WithArgs(3, 1).WillReturnRows(rent(false, nil))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionDelete, audit.EntityRecurring, int64(3), sqlmock.AnyArg(), nil, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(db).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete unknown recurrence", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "1", "3")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows(recurrenceColumns))
+		mock.ExpectRollback()
+
+		err := New(db).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid recurrence id", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "1", "abc")
+
+		err := New(nil).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,66 @@
+package recurring
+
+import (
+	"time"
+)
+
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+func validFrequency(f string) bool {
+	switch f {
+	case Daily, Weekly, Monthly, Yearly:
+		return true
+	}
+	return false
+}
+
+// addMonths moves t by n months, keeping the day of month but clamping it to
+// the last day of shorter months, so a rule starting on Jan 31 falls on
+// Feb 29 and Mar 31 instead of drifting into March.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// occurrence returns the n-th date of the rule, counting the start date as 0.
+func occurrence(frequency string, start time.Time, n int) time.Time {
+	switch frequency {
+	case Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case Monthly:
+		return addMonths(start, n)
+	case Yearly:
+		return addMonths(start, 12*n)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// Due lists the dates of r after its last run up to and including until, so a
+// scheduler that was down for a while catches up on every missed date.
+func (r Recurrence) Due(until time.Time) []time.Time {
+	if r.EndDate != nil && r.EndDate.Before(until) {
+		until = *r.EndDate
+	}
+
+	var dates []time.Time
+	for n := 0; ; n++ {
+		d := occurrence(r.Frequency, r.StartDate, n)
+		if d.After(until) {
+			return dates
+		}
+		if r.LastRun == nil || d.After(*r.LastRun) {
+			dates = append(dates, d)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestOccurrence(t *testing.T) {
+	start := date(2024, time.January, 31)
+
+	tests := []struct {
+		frequency string
+		n         int
+		want      time.Time
+	}{
+		{Daily, 1, date(2024, time.February, 1)},
+		{Weekly, 2, date(2024, time.February, 14)},
+		{Monthly, 1, date(2024, time.February, 29)},
+		{Monthly, 2, date(2024, time.March, 31)},
+		{Monthly, 13, date(2025, time.February, 28)},
+		{Yearly, 1, date(2025, time.January, 31)},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, occurrence(tc.frequency, start, tc.n), "%s %d", tc.frequency, tc.n)
+	}
+}
+
+func TestDue(t *testing.T) {
+	t.Run("first run includes the start date", func(t *testing.T) {
+		r := Recurrence{Frequency: Weekly, StartDate: date(2024, time.May, 1)}
+
+		got := r.Due(date(2024, time.May, 15))
+
+		assert.Equal(t, []time.Time{date(2024, time.May, 1), date(2024, time.May, 8), date(2024, time.May, 15)}, got)
+	})
+
+	t.Run("catch up only dates after the last run", func(t *testing.T) {
+		r := Recurrence{Frequency: Monthly, StartDate: date(2024, time.January, 25), LastRun: ptr(date(2024, time.February, 28))}
+
+		got := r.Due(date(2024, time.May, 1))
+
+		assert.Equal(t, []time.Time{date(2024, time.March, 25), date(2024, time.April, 25)}, got)
+	})
+
+	t.Run("stop at the end date", func(t *testing.T) {
+		r := Recurrence{Frequency: Daily, StartDate: date(2024, time.May, 1), EndDate: ptr(date(2024, time.May, 2))}
+
+		got := r.Due(date(2024, time.May, 10))
+
+		assert.Equal(t, []time.Time{date(2024, time.May, 1), date(2024, time.May, 2)}, got)
+	})
+
+	t.Run("nothing due before the start date", func(t *testing.T) {
+		r := Recurrence{Frequency: Daily, StartDate: date(2024, time.June, 1)}
+
+		assert.Empty(t, r.Due(date(2024, time.May, 10)))
+	})
+}
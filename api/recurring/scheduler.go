@@ -0,0 +1,130 @@
+package recurring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+
+	"go.uber.org/zap"
+)
+
+const (
+	dueStmt = `SELECT ` + columns + ` FROM recurring
+WHERE NOT paused AND start_date <= $1
+	AND spender_id IN (SELECT id FROM spender WHERE deleted_at IS NULL) AND (last_run IS NULL OR last_run < LEAST($1, COALESCE(end_date, $1)));`
+	materializeStmt = `INSERT INTO transaction (date, amount, category, transaction_type, note, image_url, spender_id, recurring_id, currency)
+VALUES ($1, $2, $3, $4, $5, '', $6, $7, (SELECT base_currency FROM spender WHERE id = $6)) ON CONFLICT (recurring_id, date) DO NOTHING RETURNING id;`
+	lastRunStmt = `UPDATE recurring SET last_run = $1 WHERE id = $2;`
+)
+
+// Scheduler periodically turns due recurrences into transactions. Each
+// recurrence is materialized in its own database transaction and the unique
+// (recurring_id, date) index makes a date that was already created a no-op,
+// so running twice, or on two instances, never duplicates a transaction.
+type Scheduler struct {
+	db       *sql.DB
+	interval time.Duration
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+func NewScheduler(db *sql.DB, interval time.Duration, logger *zap.Logger) *Scheduler {
+	return &Scheduler{db: db, interval: interval, logger: logger, now: time.Now}
+}
+
+// Start runs the scheduler immediately and then on every interval until ctx
+// is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Run(ctx); err != nil {
+			s.logger.Error("materialize recurring transactions", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run creates every transaction that is due up to today.
+func (s *Scheduler) Run(ctx context.Context) error {
+	today := s.now().UTC().Truncate(24 * time.Hour)
+
+	rows, err := s.db.QueryContext(ctx, dueStmt, today)
+	if err != nil {
+		return err
+	}
+	var due []Recurrence
+	for rows.Next() {
+		r, err := scanRecurrence(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range due {
+		if err := s.materialize(ctx, r, today); err != nil {
+			s.logger.Error("materialize recurrence", zap.Int("id", r.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) materialize(ctx context.Context, r Recurrence, today time.Time) error {
+	until := today
+	if r.EndDate != nil && r.EndDate.Before(until) {
+		until = *r.EndDate
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range r.Due(until) {
+		var id int64
+		err := tx.QueryRowContext(ctx, materializeStmt, d, r.Amount, r.Category, r.TransactionType, r.Note, r.SpenderID, r.ID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			// created by an earlier run
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = audit.Write(ctx, tx, audit.Entry{
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityTransaction,
+			EntityID: id,
+			After: map[string]any{
+				"date":             d,
+				"amount":           r.Amount,
+				"category":         r.Category,
+				"transaction_type": r.TransactionType,
+				"note":             r.Note,
+				"spender_id":       r.SpenderID,
+				"recurring_id":     r.ID,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, lastRunStmt, until, r.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,96 @@
+package recurring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSchedulerRun(t *testing.T) {
+	today := date(2024, time.May, 18)
+
+	t.Run("materialize missed dates and advance last run", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows(recurrenceColumns).
+			AddRow(1, 2, 99.5, "Music", "expense", "Subscription", Weekly, date(2024, time.May, 1), nil, date(2024, time.May, 1), false)
+		mock.ExpectQuery(dueStmt).WithArgs(today).WillReturnRows(rows)
+		mock.ExpectBegin()
+		mock.ExpectQuery(materializeStmt).WithArgs(date(2024, time.May, 8), money.MustParse("99.5"), "Music", "expense", "Subscription", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionCreate, audit.EntityTransaction, int64(10), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		// already created by an earlier run
+		mock.ExpectQuery(materializeStmt).WithArgs(date(2024, time.May, 15), money.MustParse("99.5"), "Music", "expense", "Subscription", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec(lastRunStmt).WithArgs(today, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		s := NewScheduler(db, time.Hour, zap.NewNop())
+		s.now = func() time.Time { return today.Add(10 * time.Hour) }
+		err := s.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("last run stops at the end date", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		end := date(2024, time.May, 10)
+		rows := sqlmock.NewRows(recurrenceColumns).
+			AddRow(1, 2, 10, "Food", "expense", "", Daily, date(2024, time.May, 10), end, nil, false)
+		mock.ExpectQuery(dueStmt).WithArgs(today).WillReturnRows(rows)
+		mock.ExpectBegin()
+		mock.ExpectQuery(materializeStmt).WithArgs(end, money.MustParse("10"), "Food", "expense", "", 2, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectExec(audit.InsertStmt).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(lastRunStmt).WithArgs(end, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		s := NewScheduler(db, time.Hour, zap.NewNop())
+		s.now = func() time.Time { return today }
+		err := s.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("roll back a recurrence that fails", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows(recurrenceColumns).
+			AddRow(1, 2, 10, "Food", "expense", "", Daily, today, nil, nil, false)
+		mock.ExpectQuery(dueStmt).WithArgs(today).WillReturnRows(rows)
+		mock.ExpectBegin()
+		mock.ExpectQuery(materializeStmt).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		s := NewScheduler(db, time.Hour, zap.NewNop())
+		s.now = func() time.Time { return today }
+		err := s.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(dueStmt).WillReturnError(assert.AnError)
+
+		s := NewScheduler(db, time.Hour, zap.NewNop())
+		err := s.Run(context.Background())
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,50 @@
+package slipjob
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+)
+
+// Slip is what an extractor is told about the image it reads.
+type Slip struct {
+	ID          int
+	SpenderID   int64
+	Filename    string
+	ContentType string
+	Location    string
+	CreatedAt   time.Time
+}
+
+// Draft is what an extractor read from a slip. Fields it could not read are
+// left zero: the draft is dated the day of the upload, filed under
+// "Uncategorized" and kept in the spender's base currency.
+type Draft struct {
+	Date     time.Time
+	Amount   money.Amount
+	Category string
+	Note     string
+	Currency string
+}
+
+// Extractor reads the expense on a slip image, such as with OCR or a
+// payment provider's QR code. An error fails the attempt and the job is
+// tried again later.
+type Extractor interface {
+	Extract(ctx context.Context, s Slip, image io.Reader) (Draft, error)
+}
+
+// ExtractorFunc lets an ordinary function be used as an Extractor.
+type ExtractorFunc func(ctx context.Context, s Slip, image io.Reader) (Draft, error)
+
+func (f ExtractorFunc) Extract(ctx context.Context, s Slip, image io.Reader) (Draft, error) {
+	return f(ctx, s, image)
+}
+
+// Blank reads nothing from the image. Every slip becomes an empty draft noted
+// with its file name, for the spender to fill in.
+var Blank = ExtractorFunc(func(_ context.Context, s Slip, _ io.Reader) (Draft, error) {
+	return Draft{Note: s.Filename}, nil
+})
@@ -0,0 +1,159 @@
+package slipjob
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidSpender = errors.New("invalid spender")
+	ErrInvalidJob     = errors.New("invalid job id")
+	ErrInvalidStatus  = errors.New("status must be one of pending, running, done or failed")
+	ErrNotFound       = errors.New("job not found")
+	ErrNotFailed      = errors.New("only a failed job can be retried")
+)
+
+const (
+	listStmt = `SELECT ` + columns + ` FROM slip_job j JOIN slip s ON s.id = j.slip_id
+WHERE s.spender_id = $1 AND ($2 = '' OR j.status = $2) ORDER BY j.id DESC;`
+	getStmt = `SELECT ` + columns + ` FROM slip_job j JOIN slip s ON s.id = j.slip_id
+WHERE j.id = $1 AND s.spender_id = $2;`
+	// resetStmt gives a failed job a fresh set of attempts, due at once.
+	resetStmt = `UPDATE slip_job j SET status = 'pending', attempts = 0, run_at = $1, last_error = '', updated_at = $1
+FROM slip s WHERE s.id = j.slip_id AND j.id = $2 AND s.spender_id = $3 AND j.status = 'failed'
+RETURNING ` + columns + `;`
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type handler struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func New(db *sql.DB) handler {
+	return handler{db: db, now: time.Now}
+}
+
+func spenderID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, ErrInvalidSpender
+	}
+	return id, nil
+}
+
+func jobID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("jobId"))
+	if err != nil {
+		return 0, ErrInvalidJob
+	}
+	return id, nil
+}
+
+// GetAll lists the jobs of the spender's slips, newest first, optionally only
+// those with the status in the query parameter "status".
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, err := spenderID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	status := c.QueryParam("status")
+	switch status {
+	case "", StatusPending, StatusRunning, StatusDone, StatusFailed:
+	default:
+		return c.JSON(http.StatusBadRequest, Err{Message: ErrInvalidStatus.Error()})
+	}
+
+	rows, err := h.db.QueryContext(ctx, listStmt, spID, status)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	defer rows.Close()
+
+	res := []Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
+		}
+		res = append(res, j)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetByID returns one job of the spender's slips. Once it is done,
+// transaction_id is the draft made from the slip.
+func (h handler) GetByID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, id, err := ids(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	j, err := scanJob(h.db.QueryRowContext(ctx, getStmt, id, spID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, Err{Message: ErrNotFound.Error()})
+	}
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	return c.JSON(http.StatusOK, j)
+}
+
+// Retry queues a failed job again with all of its attempts. A job that has
+// not failed is left alone with 409.
+func (h handler) Retry(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spID, id, err := ids(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	j, err := scanJob(h.db.QueryRowContext(ctx, resetStmt, h.now(), id, spID))
+	if errors.Is(err, sql.ErrNoRows) {
+		// tell a job that has not failed from one that does not exist
+		_, err = scanJob(h.db.QueryRowContext(ctx, getStmt, id, spID))
+		if err == nil {
+			return c.JSON(http.StatusConflict, Err{Message: ErrNotFailed.Error()})
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, Err{Message: ErrNotFound.Error()})
+		}
+	}
+	if err != nil {
+		logger.Error("retry job error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "retry job error"})
+	}
+	return c.JSON(http.StatusOK, j)
+}
+
+func ids(c echo.Context) (spID, id int, err error) {
+	if spID, err = spenderID(c); err != nil {
+		return 0, 0, err
+	}
+	if id, err = jobID(c); err != nil {
+		return 0, 0, err
+	}
+	return spID, id, nil
+}
@@ -0,0 +1,156 @@
+package slipjob
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var jobCols = []string{"id", "slip_id", "status", "attempts", "max_attempts", "run_at", "last_error", "transaction_id", "created_at", "updated_at"}
+
+func newContext(method, target string, ids ...string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names := []string{"id", "jobId"}
+	c.SetParamNames(names[:len(ids)]...)
+	c.SetParamValues(ids...)
+	return c, rec
+}
+
+func newHandler() (handler, sqlmock.Sqlmock, *sql.DB) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	h := New(db)
+	h.now = func() time.Time { return now }
+	return h, mock, db
+}
+
+func TestGetAll(t *testing.T) {
+	t.Run("should list the jobs with the status", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?status=failed", "1")
+		h, mock, db := newHandler()
+		defer db.Close()
+
+		mock.ExpectQuery(listStmt).WithArgs(1, "failed").WillReturnRows(sqlmock.NewRows(jobCols).
+			AddRow(3, 7, "failed", 5, 5, now, "extract: unreadable", nil, uploaded, now))
+
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var jobs []Job
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jobs))
+		assert.Equal(t, []Job{{ID: 3, SlipID: 7, Status: StatusFailed, Attempts: 5, MaxAttempts: 5, RunAt: now,
+			LastError: "extract: unreadable", CreatedAt: uploaded, UpdatedAt: now}}, jobs)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should reject an unknown status", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/?status=lost", "1")
+
+		err := New(nil).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"status must be one of pending, running, done or failed"}`, rec.Body.String())
+	})
+}
+
+func TestGetByID(t *testing.T) {
+	t.Run("should return the job with its draft", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/", "1", "3")
+		h, mock, db := newHandler()
+		defer db.Close()
+
+		mock.ExpectQuery(getStmt).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows(jobCols).
+			AddRow(3, 7, "done", 1, 5, now, "", 42, uploaded, now))
+
+		err := h.GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"transaction_id":42`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return 404 for a job of another spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/", "1", "3")
+		h, mock, db := newHandler()
+		defer db.Close()
+
+		mock.ExpectQuery(getStmt).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows(jobCols))
+
+		err := h.GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should reject an invalid job id", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "/", "1", "x")
+
+		err := New(nil).GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("should queue a failed job again", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", "1", "3")
+		h, mock, db := newHandler()
+		defer db.Close()
+
+		mock.ExpectQuery(resetStmt).WithArgs(now, 3, 1).WillReturnRows(sqlmock.NewRows(jobCols).
+			AddRow(3, 7, "pending", 0, 5, now, "", nil, uploaded, now))
+
+		err := h.Retry(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"pending"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return 409 for a job that has not failed", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", "1", "3")
+		h, mock, db := newHandler()
+		defer db.Close()
+
+		mock.ExpectQuery(resetStmt).WithArgs(now, 3, 1).WillReturnRows(sqlmock.NewRows(jobCols))
+		mock.ExpectQuery(getStmt).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows(jobCols).
+			AddRow(3, 7, "running", 1, 5, now, "", nil, uploaded, now))
+
+		err := h.Retry(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.JSONEq(t, `{"message":"only a failed job can be retried"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should return 404 for a missing job", func(t *testing.T) {
+		c, rec := newContext(http.MethodPost, "/", "1", "3")
+		h, mock, db := newHandler()
+		defer db.Close()
+
+		mock.ExpectQuery(resetStmt).WithArgs(now, 3, 1).WillReturnRows(sqlmock.NewRows(jobCols))
+		mock.ExpectQuery(getStmt).WithArgs(3, 1).WillReturnRows(sqlmock.NewRows(jobCols))
+
+		err := h.Retry(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
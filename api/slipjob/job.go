@@ -0,0 +1,91 @@
+package slipjob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// maxBackoff caps the wait between two attempts of a job.
+const maxBackoff = time.Hour
+
+const (
+	columns     = `j.id, j.slip_id, j.status, j.attempts, j.max_attempts, j.run_at, j.last_error, j.transaction_id, j.created_at, j.updated_at`
+	enqueueStmt = `INSERT INTO slip_job (slip_id, max_attempts) VALUES ($1, $2) RETURNING id;`
+	// claimStmt takes the oldest due job that no other worker holds. A
+	// running job is due again once its lease has run out.
+	claimStmt = `UPDATE slip_job SET status = 'running', attempts = attempts + 1, run_at = $2, updated_at = $1
+WHERE id = (SELECT id FROM slip_job WHERE status IN ('pending', 'running') AND run_at <= $1
+	ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED)
+RETURNING id, slip_id, attempts, max_attempts;`
+	// doneStmt and retryStmt only touch the job while this attempt still
+	// holds it, so a worker whose lease ran out cannot overwrite the outcome
+	// of the worker that took over.
+	doneStmt = `UPDATE slip_job SET status = 'done', transaction_id = $1, last_error = '', updated_at = $2
+WHERE id = $3 AND status = 'running' AND attempts = $4;`
+	retryStmt = `UPDATE slip_job SET status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+	run_at = $1, last_error = $2, updated_at = $3
+WHERE id = $4 AND status = 'running' AND attempts = $5;`
+)
+
+var errLeaseLost = errors.New("job was taken over by another worker")
+
+// Job is the extraction of one uploaded slip. It is pending until a worker
+// claims it, running while a worker holds it and done once the slip has a
+// transaction. A job that fails is tried again later, with a wait that
+// doubles on every attempt, until it has used up its attempts and is failed.
+type Job struct {
+	ID            int       `json:"id"`
+	SlipID        int       `json:"slip_id"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	MaxAttempts   int       `json:"max_attempts"`
+	RunAt         time.Time `json:"run_at"`
+	LastError     string    `json:"last_error"`
+	TransactionID *int64    `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row scanner) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.SlipID, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError,
+		&j.TransactionID, &j.CreatedAt, &j.UpdatedAt)
+	return j, err
+}
+
+// Queuer is implemented by *sql.Tx. A job is enqueued in the transaction that
+// records its slip, so a slip is never stored without its job.
+type Queuer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Enqueue adds a job for the slip that can be attempted maxAttempts times and
+// returns its id. Workers pick it up on their next poll.
+func Enqueue(ctx context.Context, tx Queuer, slipID, maxAttempts int) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, enqueueStmt, slipID, maxAttempts).Scan(&id)
+	return id, err
+}
+
+// backoff is how long a job waits after its nth failed attempt: base, then
+// twice as long after every further failure, up to maxBackoff.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
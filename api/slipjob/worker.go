@@ -0,0 +1,211 @@
+package slipjob
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/storage"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"go.uber.org/zap"
+)
+
+const defaultCategory = "Uncategorized"
+
+const (
+	slipStmt  = `SELECT id, spender_id, transaction_id, storage_key, location, filename, content_type, created_at FROM slip WHERE id = $1;`
+	draftStmt = `INSERT INTO transaction (date, amount, category, transaction_type, note, image_url, spender_id, currency, draft)
+VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8, ''), (SELECT base_currency FROM spender WHERE id = $7), 'THB'), TRUE) RETURNING id, currency;`
+	attachStmt = `UPDATE slip SET transaction_id = $1 WHERE id = $2 AND transaction_id IS NULL;`
+)
+
+// errAttached means the spender attached the slip to a transaction while it
+// was being read, so no draft is needed.
+var errAttached = errors.New("slip is already attached to a transaction")
+
+// Worker turns queued slips into draft expenses. Any number of workers, in
+// this process or others, can share the queue: each job is claimed by one of
+// them at a time, and a job whose worker stopped is claimed again once its
+// lease runs out.
+type Worker struct {
+	db        *sql.DB
+	store     storage.Storage
+	extractor Extractor
+	cfg       config.Scheduler
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+func NewWorker(db *sql.DB, store storage.Storage, extractor Extractor, cfg config.Scheduler, logger *zap.Logger) *Worker {
+	return &Worker{db: db, store: store, extractor: extractor, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// Start runs cfg.SlipWorkers workers until ctx is cancelled. A worker works
+// through due jobs one after the other and polls every cfg.SlipPollInterval
+// once there are none left.
+func (w *Worker) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.SlipWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.poll(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.SlipPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ran, err := w.Run(ctx)
+		if err != nil {
+			w.logger.Error("process slip job", zap.Error(err))
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run claims one due job and processes it. It reports false when no job was
+// due. A failed attempt is scheduled again, or the job is failed once it has
+// no attempts left; the returned error is only for failures of the queue.
+func (w *Worker) Run(ctx context.Context) (bool, error) {
+	now := w.now()
+	var j Job
+	err := w.db.QueryRowContext(ctx, claimStmt, now, now.Add(w.cfg.SlipLease)).
+		Scan(&j.ID, &j.SlipID, &j.Attempts, &j.MaxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if j.Attempts > j.MaxAttempts {
+		// the worker of the last attempt stopped before it finished
+		err = errors.New("job did not finish in time")
+	} else {
+		err = w.process(ctx, j)
+	}
+	if err == nil || errors.Is(err, errLeaseLost) {
+		return true, err
+	}
+
+	w.logger.Error("extract slip", zap.Int("job_id", j.ID), zap.Int("slip_id", j.SlipID),
+		zap.Int("attempt", j.Attempts), zap.Error(err))
+	now = w.now()
+	_, err = w.db.ExecContext(ctx, retryStmt, now.Add(backoff(w.cfg.SlipRetryBackoff, j.Attempts)),
+		err.Error(), now, j.ID, j.Attempts)
+	return true, err
+}
+
+// process reads the slip of the job and stores what was read as a draft
+// expense attached to the slip.
+func (w *Worker) process(ctx context.Context, j Job) error {
+	var s Slip
+	var key string
+	var transactionID *int64
+	err := w.db.QueryRowContext(ctx, slipStmt, j.SlipID).
+		Scan(&s.ID, &s.SpenderID, &transactionID, &key, &s.Location, &s.Filename, &s.ContentType, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("load slip: %w", err)
+	}
+	if transactionID != nil {
+		return w.done(ctx, w.db, j, transactionID)
+	}
+
+	image, err := w.store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("read slip: %w", err)
+	}
+	defer image.Close()
+	d, err := w.extractor.Extract(ctx, s, image)
+	if err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+
+	err = w.draft(ctx, j, s, d)
+	if errors.Is(err, errAttached) {
+		return w.done(ctx, w.db, j, nil)
+	}
+	return err
+}
+
+func (w *Worker) draft(ctx context.Context, j Job, s Slip, d Draft) error {
+	if d.Date.IsZero() {
+		d.Date = s.CreatedAt
+	}
+	if d.Category == "" {
+		d.Category = defaultCategory
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var currency string
+	err = tx.QueryRowContext(ctx, draftStmt, d.Date, d.Amount, d.Category, txtype.Expense, d.Note, s.Location,
+		s.SpenderID, d.Currency).Scan(&id, &currency)
+	if err != nil {
+		return fmt.Errorf("insert draft: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, attachStmt, id, s.ID)
+	if err != nil {
+		return fmt.Errorf("attach slip: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return errAttached
+	}
+	err = audit.Write(ctx, tx, audit.Entry{
+		Action:   audit.ActionCreate,
+		Entity:   audit.EntityTransaction,
+		EntityID: id,
+		After: map[string]any{
+			"date":             d.Date,
+			"amount":           d.Amount,
+			"category":         d.Category,
+			"transaction_type": txtype.Expense,
+			"note":             d.Note,
+			"image_url":        s.Location,
+			"spender_id":       s.SpenderID,
+			"currency":         currency,
+			"draft":            true,
+			"slip_id":          s.ID,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.done(ctx, tx, j, &id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (w *Worker) done(ctx context.Context, tx audit.Execer, j Job, transactionID *int64) error {
+	result, err := tx.ExecContext(ctx, doneStmt, transactionID, w.now(), j.ID, j.Attempts)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
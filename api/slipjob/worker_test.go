@@ -0,0 +1,170 @@
+package slipjob
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/storage"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	now       = time.Date(2024, time.May, 18, 10, 0, 0, 0, time.UTC)
+	uploaded  = time.Date(2024, time.May, 17, 21, 30, 0, 0, time.UTC)
+	schedule  = config.Scheduler{SlipWorkers: 1, SlipPollInterval: time.Second, SlipLease: 5 * time.Minute, SlipRetryBackoff: 30 * time.Second}
+	claimCols = []string{"id", "slip_id", "attempts", "max_attempts"}
+	slipCols  = []string{"id", "spender_id", "transaction_id", "storage_key", "location", "filename", "content_type", "created_at"}
+)
+
+const key = "slips/ab/abcdef.png"
+
+func newWorker(t *testing.T, extractor Extractor) (*Worker, sqlmock.Sqlmock, *sql.DB) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	store := storage.NewLocal(t.TempDir(), "")
+	_, err := store.Put(context.Background(), key, strings.NewReader("image"), 5, "image/png")
+	assert.NoError(t, err)
+
+	w := NewWorker(db, store, extractor, schedule, zap.NewNop())
+	w.now = func() time.Time { return now }
+	return w, mock, db
+}
+
+func expectClaim(mock sqlmock.Sqlmock, attempts, maxAttempts int) {
+	mock.ExpectQuery(claimStmt).WithArgs(now, now.Add(5*time.Minute)).
+		WillReturnRows(sqlmock.NewRows(claimCols).AddRow(3, 7, attempts, maxAttempts))
+}
+
+func expectSlip(mock sqlmock.Sqlmock, transactionID any) {
+	mock.ExpectQuery(slipStmt).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(slipCols).AddRow(7, 1, transactionID, key, "/slips/ab/abcdef.png", "slip.png", "image/png", uploaded))
+}
+
+func TestWorkerRun(t *testing.T) {
+	t.Run("should create a draft expense from the slip", func(t *testing.T) {
+		var read string
+		extractor := ExtractorFunc(func(_ context.Context, s Slip, image io.Reader) (Draft, error) {
+			b, _ := io.ReadAll(image)
+			read = string(b)
+			return Draft{Amount: money.MustParse("120.50"), Note: "Coffee " + s.Filename}, nil
+		})
+		w, mock, db := newWorker(t, extractor)
+		defer db.Close()
+
+		expectClaim(mock, 1, 5)
+		expectSlip(mock, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery(draftStmt).
+			WithArgs(uploaded, money.MustParse("120.50"), "Uncategorized", "expense", "Coffee slip.png", "/slips/ab/abcdef.png", int64(1), "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(42, "THB"))
+		mock.ExpectExec(attachStmt).WithArgs(int64(42), 7).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionCreate, audit.EntityTransaction, int64(42), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(doneStmt).WithArgs(int64(42), now, 3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ran, err := w.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.Equal(t, "image", read)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should report when no job is due", func(t *testing.T) {
+		w, mock, db := newWorker(t, Blank)
+		defer db.Close()
+
+		mock.ExpectQuery(claimStmt).WithArgs(now, now.Add(5*time.Minute)).WillReturnRows(sqlmock.NewRows(claimCols))
+
+		ran, err := w.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.False(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should finish without a draft when the slip is already attached", func(t *testing.T) {
+		w, mock, db := newWorker(t, Blank)
+		defer db.Close()
+
+		expectClaim(mock, 1, 5)
+		expectSlip(mock, 12)
+		mock.ExpectExec(doneStmt).WithArgs(int64(12), now, 3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ran, err := w.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should retry a failed attempt after a backoff", func(t *testing.T) {
+		extractor := ExtractorFunc(func(context.Context, Slip, io.Reader) (Draft, error) {
+			return Draft{}, assert.AnError
+		})
+		w, mock, db := newWorker(t, extractor)
+		defer db.Close()
+
+		expectClaim(mock, 3, 5)
+		expectSlip(mock, nil)
+		mock.ExpectExec(retryStmt).WithArgs(now.Add(2*time.Minute), "extract: "+assert.AnError.Error(), now, 3, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ran, err := w.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should fail a job whose last worker stopped", func(t *testing.T) {
+		w, mock, db := newWorker(t, Blank)
+		defer db.Close()
+
+		expectClaim(mock, 6, 5)
+		mock.ExpectExec(retryStmt).WithArgs(sqlmock.AnyArg(), "job did not finish in time", now, 3, 6).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ran, err := w.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.True(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should not overwrite a job taken over by another worker", func(t *testing.T) {
+		w, mock, db := newWorker(t, Blank)
+		defer db.Close()
+
+		expectClaim(mock, 1, 5)
+		expectSlip(mock, nil)
+		mock.ExpectBegin()
+		mock.ExpectQuery(draftStmt).WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(42, "THB"))
+		mock.ExpectExec(attachStmt).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(audit.InsertStmt).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(doneStmt).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		ran, err := w.Run(context.Background())
+
+		assert.ErrorIs(t, err, errLeaseLost)
+		assert.True(t, ran)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(30*time.Second, 1))
+	assert.Equal(t, time.Minute, backoff(30*time.Second, 2))
+	assert.Equal(t, 4*time.Minute, backoff(30*time.Second, 4))
+	assert.Equal(t, time.Hour, backoff(30*time.Second, 20))
+}
@@ -1,10 +1,17 @@
 package spender
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
+	"net/mail"
 
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/currency"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dberr"
 	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -14,6 +21,24 @@ type Spender struct {
 	ID    int64  `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+
+	BaseCurrency string `json:"base_currency,omitempty"`
+}
+
+// updateRequest is shared by PUT, which requires every field, and PATCH,
+// which leaves a missing field unchanged.
+type updateRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+type currencyRequest struct {
+	BaseCurrency string `json:"base_currency"`
 }
 
 type handler struct {
@@ -26,9 +51,69 @@ func New(cfg config.FeatureFlag, db *sql.DB) *handler {
 }
 
 const (
-	cStmt = `INSERT INTO spender (name, email) VALUES ($1, $2) RETURNING id;`
+	cStmt          = `INSERT INTO spender (name, email) VALUES ($1, $2) RETURNING id;`
+	allStmt        = `SELECT id, name, email, role, base_currency FROM spender WHERE deleted_at IS NULL`
+	getStmt        = `SELECT id, name, email, role, base_currency FROM spender WHERE id = $1 AND deleted_at IS NULL;`
+	lockStmt       = `SELECT id, name, email, role, base_currency FROM spender WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;`
+	updateStmt     = `UPDATE spender SET name = COALESCE($1, name), email = COALESCE($2, email) WHERE id = $3 AND deleted_at IS NULL RETURNING id, name, email, role, base_currency;`
+	softDeleteStmt = `UPDATE spender SET deleted_at = now(), token_version = token_version + 1 WHERE id = $1 AND deleted_at IS NULL;`
+	hardDeleteStmt = `DELETE FROM spender WHERE id = $1;`
+	roleStmt       = `UPDATE spender SET role = $1 WHERE id = $2 AND deleted_at IS NULL RETURNING id, name, email, role, base_currency;`
+	currencyStmt   = `UPDATE spender SET base_currency = $1 WHERE id = $2 AND deleted_at IS NULL RETURNING id, name, email, role, base_currency;`
+)
+
+const (
+	msgNotFound     = "spender not found"
+	msgInvalidEmail = "email is invalid"
+	msgEmailTaken   = "email is already registered"
 )
 
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSpender(row scanner) (Spender, error) {
+	var sp Spender
+	err := row.Scan(&sp.ID, &sp.Name, &sp.Email, &sp.Role, &sp.BaseCurrency)
+	return sp, err
+}
+
+// change locks the spender of the request, applies mutate and records the
+// spender before and after it in the audit log, all in one database
+// transaction. mutate returns nil when it deleted the spender. A missing
+// spender is reported as sql.ErrNoRows.
+func (h handler) change(c echo.Context, action string, mutate func(ctx context.Context, tx *sql.Tx) (*Spender, error)) (*Spender, error) {
+	ctx := c.Request().Context()
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := scanSpender(tx.QueryRowContext(ctx, lockStmt, c.Param("id")))
+	if err != nil {
+		return nil, err
+	}
+	after, err := mutate(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := audit.Entry{Action: action, Entity: audit.EntitySpender, EntityID: before.ID, Before: before}
+	if after != nil {
+		entry.After = *after
+	}
+	if err := audit.Record(c, tx, entry); err != nil {
+		return nil, err
+	}
+	return after, tx.Commit()
+}
+
 func (h handler) Create(c echo.Context) error {
 	if !h.flag.EnableCreateSpender {
 		return c.JSON(http.StatusForbidden, "create new spender feature is disabled")
@@ -42,16 +127,37 @@ func (h handler) Create(c echo.Context) error {
 		logger.Error("bad request body", zap.Error(err))
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
+	if !validEmail(sp.Email) {
+		return c.JSON(http.StatusBadRequest, msgInvalidEmail)
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	defer tx.Rollback()
 
 	var lastInsertId int64
-	err = h.db.QueryRowContext(ctx, cStmt, sp.Name, sp.Email).Scan(&lastInsertId)
+	err = tx.QueryRowContext(ctx, cStmt, sp.Name, sp.Email).Scan(&lastInsertId)
+	if dberr.IsUnique(err) {
+		return c.JSON(http.StatusConflict, msgEmailTaken)
+	}
 	if err != nil {
 		logger.Error("query row error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
+	sp.ID = lastInsertId
+	err = audit.Record(c, tx, audit.Entry{Action: audit.ActionCreate, Entity: audit.EntitySpender, EntityID: sp.ID, After: sp})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		logger.Error("commit error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
 
 	logger.Info("create successfully", zap.Int64("id", lastInsertId))
-	sp.ID = lastInsertId
 	return c.JSON(http.StatusCreated, sp)
 }
 
@@ -59,7 +165,7 @@ func (h handler) GetAll(c echo.Context) error {
 	logger := mlog.L(c)
 	ctx := c.Request().Context()
 
-	rows, err := h.db.QueryContext(ctx, `SELECT id, name, email FROM spender`)
+	rows, err := h.db.QueryContext(ctx, allStmt)
 	if err != nil {
 		logger.Error("query error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, err.Error())
@@ -68,8 +174,7 @@ func (h handler) GetAll(c echo.Context) error {
 
 	var sps []Spender
 	for rows.Next() {
-		var sp Spender
-		err := rows.Scan(&sp.ID, &sp.Name, &sp.Email)
+		sp, err := scanSpender(rows)
 		if err != nil {
 			logger.Error("scan error", zap.Error(err))
 			return c.JSON(http.StatusInternalServerError, err.Error())
@@ -79,3 +184,166 @@ func (h handler) GetAll(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, sps)
 }
+
+func (h handler) GetByID(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	sp, err := scanSpender(h.db.QueryRowContext(ctx, getStmt, c.Param("id")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, msgNotFound)
+	}
+	if err != nil {
+		logger.Error("query row error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, sp)
+}
+
+// Update replaces the name and email of the spender.
+func (h handler) Update(c echo.Context) error {
+	var req updateRequest
+	err := c.Bind(&req)
+	if err != nil {
+		mlog.L(c).Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if req.Name == nil || req.Email == nil {
+		return c.JSON(http.StatusBadRequest, "name and email are required")
+	}
+
+	return h.update(c, req)
+}
+
+// Patch changes only the fields present in the body.
+func (h handler) Patch(c echo.Context) error {
+	var req updateRequest
+	err := c.Bind(&req)
+	if err != nil {
+		mlog.L(c).Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if req.Name == nil && req.Email == nil {
+		return c.JSON(http.StatusBadRequest, "name or email is required")
+	}
+
+	return h.update(c, req)
+}
+
+func (h handler) update(c echo.Context, req updateRequest) error {
+	logger := mlog.L(c)
+
+	if req.Name != nil && *req.Name == "" {
+		return c.JSON(http.StatusBadRequest, "name must not be empty")
+	}
+	if req.Email != nil && !validEmail(*req.Email) {
+		return c.JSON(http.StatusBadRequest, msgInvalidEmail)
+	}
+
+	sp, err := h.change(c, audit.ActionUpdate, func(ctx context.Context, tx *sql.Tx) (*Spender, error) {
+		sp, err := scanSpender(tx.QueryRowContext(ctx, updateStmt, req.Name, req.Email, c.Param("id")))
+		return &sp, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, msgNotFound)
+	}
+	if dberr.IsUnique(err) {
+		return c.JSON(http.StatusConflict, msgEmailTaken)
+	}
+	if err != nil {
+		logger.Error("update spender error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("update spender successfully", zap.Int64("id", sp.ID))
+	return c.JSON(http.StatusOK, sp)
+}
+
+// Delete removes the spender. With EnableSoftDeleteSpender the spender is
+// only marked as deleted and their transactions are hidden; otherwise the
+// spender is removed together with their transactions, budgets and
+// recurrences. Either way their tokens stop working.
+func (h handler) Delete(c echo.Context) error {
+	logger := mlog.L(c)
+
+	stmt := hardDeleteStmt
+	if h.flag.EnableSoftDeleteSpender {
+		stmt = softDeleteStmt
+	}
+	_, err := h.change(c, audit.ActionDelete, func(ctx context.Context, tx *sql.Tx) (*Spender, error) {
+		_, err := tx.ExecContext(ctx, stmt, c.Param("id"))
+		return nil, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, msgNotFound)
+	}
+	if err != nil {
+		logger.Error("delete spender error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("delete spender successfully", zap.String("id", c.Param("id")), zap.Bool("soft", h.flag.EnableSoftDeleteSpender))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h handler) UpdateRole(c echo.Context) error {
+	logger := mlog.L(c)
+
+	var req roleRequest
+	err := c.Bind(&req)
+	if err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if req.Role != auth.RoleAdmin && req.Role != auth.RoleSpender {
+		return c.JSON(http.StatusBadRequest, "role must be admin or spender")
+	}
+
+	sp, err := h.change(c, audit.ActionUpdate, func(ctx context.Context, tx *sql.Tx) (*Spender, error) {
+		sp, err := scanSpender(tx.QueryRowContext(ctx, roleStmt, req.Role, c.Param("id")))
+		return &sp, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, msgNotFound)
+	}
+	if err != nil {
+		logger.Error("update role error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("update role successfully", zap.Int64("id", sp.ID), zap.String("role", sp.Role))
+	return c.JSON(http.StatusOK, sp)
+}
+
+// UpdateBaseCurrency sets the currency that summaries of the spender are
+// converted into.
+func (h handler) UpdateBaseCurrency(c echo.Context) error {
+	logger := mlog.L(c)
+
+	var req currencyRequest
+	err := c.Bind(&req)
+	if err != nil {
+		logger.Error("bad request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	code, err := currency.Normalize(req.BaseCurrency)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	sp, err := h.change(c, audit.ActionUpdate, func(ctx context.Context, tx *sql.Tx) (*Spender, error) {
+		sp, err := scanSpender(tx.QueryRowContext(ctx, currencyStmt, code, c.Param("id")))
+		return &sp, err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, msgNotFound)
+	}
+	if err != nil {
+		logger.Error("update base currency error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	logger.Info("update base currency successfully", zap.Int64("id", sp.ID), zap.String("base_currency", sp.BaseCurrency))
+	return c.JSON(http.StatusOK, sp)
+}
@@ -20,6 +20,10 @@ func TestCreateSpenderIT(t *testing.T) {
 	t.Run("create spender successfully when feature toggle is enable", func(t *testing.T) {
 		sql := getTestDatabaseFromConfig(t)
 
+		t.Cleanup(func() {
+			sql.Exec(`DELETE FROM spender WHERE email = $1`, "hong@jot.ok")
+		})
+
 		h := New(config.FeatureFlag{EnableCreateSpender: true}, sql)
 		e := echo.New()
 		defer e.Close()
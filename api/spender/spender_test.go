@@ -7,8 +7,10 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,7 +29,11 @@ func TestCreateSpender(t *testing.T) {
 		defer db.Close()
 
 		row := sqlmock.NewRows([]string{"id"}).AddRow(1)
+		mock.ExpectBegin()
 		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnRows(row)
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionCreate, audit.EntitySpender, int64(1), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		cfg := config.FeatureFlag{EnableCreateSpender: true}
 
 		h := New(cfg, db)
@@ -74,6 +80,29 @@ func TestCreateSpender(t *testing.T) {
 		assert.Contains(t, rec.Body.String(), "invalid character")
 	})
 
+	t.Run("create spender failed when email already exists", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name": "HongJot", "email": "hong@jot.ok"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnError(&pq.Error{Code: "23505"})
+		cfg := config.FeatureFlag{EnableCreateSpender: true}
+
+		h := New(cfg, db)
+		err := h.Create(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
 	t.Run("create spender failed on database (feature toggle is enable) ", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
@@ -86,6 +115,7 @@ func TestCreateSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
+		mock.ExpectBegin()
 		mock.ExpectQuery(cStmt).WithArgs("HongJot", "hong@jot.ok").WillReturnError(assert.AnError)
 		cfg := config.FeatureFlag{EnableCreateSpender: true}
 
@@ -109,18 +139,18 @@ func TestGetAllSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"id", "name", "email"}).
-			AddRow(1, "HongJot", "hong@jot.ok").
-			AddRow(2, "JotHong", "jot@jot.ok")
-		mock.ExpectQuery(`SELECT id, name, email FROM spender`).WillReturnRows(rows)
+		rows := sqlmock.NewRows([]string{"id", "name", "email", "role", "base_currency"}).
+			AddRow(1, "HongJot", "hong@jot.ok", "admin", "THB").
+			AddRow(2, "JotHong", "jot@jot.ok", "spender", "USD")
+		mock.ExpectQuery(allStmt).WillReturnRows(rows)
 
 		h := New(config.FeatureFlag{}, db)
 		err := h.GetAll(c)
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.JSONEq(t, `[{"id": 1, "name": "HongJot", "email": "hong@jot.ok"},
-		{"id": 2, "name": "JotHong", "email": "jot@jot.ok"}]`, rec.Body.String())
+		assert.JSONEq(t, `[{"id": 1, "name": "HongJot", "email": "hong@jot.ok", "role": "admin", "base_currency": "THB"},
+		{"id": 2, "name": "JotHong", "email": "jot@jot.ok", "role": "spender", "base_currency": "USD"}]`, rec.Body.String())
 	})
 
 	t.Run("get all spender failed on database", func(t *testing.T) {
@@ -134,7 +164,7 @@ func TestGetAllSpender(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT id, name, email FROM spender`).WillReturnError(assert.AnError)
+		mock.ExpectQuery(allStmt).WillReturnError(assert.AnError)
 
 		h := New(config.FeatureFlag{}, db)
 		err := h.GetAll(c)
@@ -143,3 +173,319 @@ func TestGetAllSpender(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
 }
+
+func TestUpdateRole(t *testing.T) {
+	t.Run("update role successfully", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "admin"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		row := sqlmock.NewRows(spenderColumns).AddRow(1, "HongJot", "hong@jot.ok", "admin", "THB")
+		mock.ExpectQuery(roleStmt).WithArgs("admin", "1").WillReturnRows(row)
+		expectAudit(mock, audit.ActionUpdate)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.UpdateRole(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok", "role": "admin", "base_currency": "THB"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update role failed when role is unknown", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "root"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.UpdateRole(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("update role failed when spender not found", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"role": "spender"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("99")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs("99").WillReturnRows(sqlmock.NewRows(spenderColumns))
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.UpdateRole(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestUpdateBaseCurrency(t *testing.T) {
+	t.Run("update base currency successfully", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"base_currency": "usd"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		row := sqlmock.NewRows(spenderColumns).AddRow(1, "HongJot", "hong@jot.ok", "spender", "USD")
+		mock.ExpectQuery(currencyStmt).WithArgs("USD", "1").WillReturnRows(row)
+		expectAudit(mock, audit.ActionUpdate)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.UpdateBaseCurrency(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok", "role": "spender", "base_currency": "USD"}`, rec.Body.String())
+	})
+
+	t.Run("update base currency failed when code is invalid", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"base_currency": "dollar"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(config.FeatureFlag{}, nil)
+		err := h.UpdateBaseCurrency(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+var spenderColumns = []string{"id", "name", "email", "role", "base_currency"}
+
+// expectLock expects a change to begin by locking spender 1 under the given
+// id parameter.
+func expectLock(mock sqlmock.Sqlmock, id string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockStmt).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(spenderColumns).AddRow(1, "HongJot", "hong@jot.ok", "spender", "THB"))
+}
+
+func expectAudit(mock sqlmock.Sqlmock, action string) {
+	mock.ExpectExec(audit.InsertStmt).WithArgs(nil, action, audit.EntitySpender, int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func newContext(method, body, id string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func TestGetSpenderByID(t *testing.T) {
+	t.Run("get spender successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "", "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		row := sqlmock.NewRows([]string{"id", "name", "email", "role", "base_currency"}).AddRow(1, "HongJot", "hong@jot.ok", "spender", "THB")
+		mock.ExpectQuery(getStmt).WithArgs("1").WillReturnRows(row)
+
+		err := New(config.FeatureFlag{}, db).GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "HongJot", "email": "hong@jot.ok", "role": "spender", "base_currency": "THB"}`, rec.Body.String())
+	})
+
+	t.Run("get spender failed when spender does not exist", func(t *testing.T) {
+		c, rec := newContext(http.MethodGet, "", "99")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(getStmt).WithArgs("99").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role", "base_currency"}))
+
+		err := New(config.FeatureFlag{}, db).GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestUpdateSpender(t *testing.T) {
+	columns := []string{"id", "name", "email", "role", "base_currency"}
+
+	t.Run("replace spender successfully", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"name": "JotHong", "email": "jot@hong.ok"}`, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		row := sqlmock.NewRows(columns).AddRow(1, "JotHong", "jot@hong.ok", "spender", "THB")
+		mock.ExpectQuery(updateStmt).WithArgs("JotHong", "jot@hong.ok", "1").WillReturnRows(row)
+		expectAudit(mock, audit.ActionUpdate)
+
+		err := New(config.FeatureFlag{}, db).Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id": 1, "name": "JotHong", "email": "jot@hong.ok", "role": "spender", "base_currency": "THB"}`, rec.Body.String())
+	})
+
+	t.Run("patch only the name", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, `{"name": "JotHong"}`, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		row := sqlmock.NewRows(columns).AddRow(1, "JotHong", "hong@jot.ok", "spender", "THB")
+		mock.ExpectQuery(updateStmt).WithArgs("JotHong", nil, "1").WillReturnRows(row)
+		expectAudit(mock, audit.ActionUpdate)
+
+		err := New(config.FeatureFlag{}, db).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("replace spender failed when a field is missing", func(t *testing.T) {
+		c, rec := newContext(http.MethodPut, `{"name": "JotHong"}`, "1")
+
+		err := New(config.FeatureFlag{}, nil).Update(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("patch failed when body is empty", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, `{}`, "1")
+
+		err := New(config.FeatureFlag{}, nil).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("update failed when email is invalid", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, `{"email": "not-an-email"}`, "1")
+
+		err := New(config.FeatureFlag{}, nil).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `"email is invalid"`, rec.Body.String())
+	})
+
+	t.Run("update failed when spender does not exist", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, `{"name": "JotHong"}`, "99")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs("99").WillReturnRows(sqlmock.NewRows(columns))
+
+		err := New(config.FeatureFlag{}, db).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("update failed when email belongs to another spender", func(t *testing.T) {
+		c, rec := newContext(http.MethodPatch, `{"email": "jot@hong.ok"}`, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		mock.ExpectQuery(updateStmt).WithArgs(nil, "jot@hong.ok", "1").WillReturnError(&pq.Error{Code: "23505"})
+
+		err := New(config.FeatureFlag{}, db).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+}
+
+func TestDeleteSpender(t *testing.T) {
+	t.Run("hard delete spender when soft delete is disabled", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		mock.ExpectExec(hardDeleteStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionDelete, audit.EntitySpender, int64(1), sqlmock.AnyArg(), nil, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(config.FeatureFlag{}, db).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("soft delete spender when soft delete is enabled", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		expectLock(mock, "1")
+		mock.ExpectExec(softDeleteStmt).WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock, audit.ActionDelete)
+
+		err := New(config.FeatureFlag{EnableSoftDeleteSpender: true}, db).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete failed when spender does not exist", func(t *testing.T) {
+		c, rec := newContext(http.MethodDelete, "", "99")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(lockStmt).WithArgs("99").WillReturnRows(sqlmock.NewRows(spenderColumns))
+
+		err := New(config.FeatureFlag{}, db).Delete(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,167 @@
+package statement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+)
+
+// Mapping names the CSV header of each transaction field. Date and Amount
+// columns must exist; the others are optional. Without a transaction_type
+// column a negative amount is an expense and any other amount an income.
+// DecimalSeparator is "." or ","; the other one separates thousands.
+type Mapping struct {
+	Date             string `json:"date"`
+	Amount           string `json:"amount"`
+	Type             string `json:"transaction_type"`
+	Currency         string `json:"currency"`
+	Category         string `json:"category"`
+	Note             string `json:"note"`
+	DateLayout       string `json:"date_layout"`
+	DecimalSeparator string `json:"decimal_separator"`
+}
+
+// DefaultMapping reads the same field names as the transaction API.
+var DefaultMapping = Mapping{
+	Date:             "date",
+	Amount:           "amount",
+	Type:             "transaction_type",
+	Currency:         "currency",
+	Category:         "category",
+	Note:             "note",
+	DateLayout:       time.DateOnly,
+	DecimalSeparator: ".",
+}
+
+var errThousands = errors.New("amount must group thousands in threes; set decimal_separator for a decimal comma")
+
+// withDefaults fills the fields m leaves empty from DefaultMapping.
+func (m Mapping) withDefaults() Mapping {
+	def := func(v *string, d string) {
+		if strings.TrimSpace(*v) == "" {
+			*v = d
+		}
+	}
+	def(&m.Date, DefaultMapping.Date)
+	def(&m.Amount, DefaultMapping.Amount)
+	def(&m.Type, DefaultMapping.Type)
+	def(&m.Currency, DefaultMapping.Currency)
+	def(&m.Category, DefaultMapping.Category)
+	def(&m.Note, DefaultMapping.Note)
+	def(&m.DateLayout, DefaultMapping.DateLayout)
+	def(&m.DecimalSeparator, DefaultMapping.DecimalSeparator)
+	return m
+}
+
+// amount parses raw with the separators of m. A thousands separator that is
+// not followed by exactly three digits is refused rather than dropped, so
+// "12,50" is never read as 1250 when the file uses a decimal comma.
+func (m Mapping) amount(raw string) (money.Amount, error) {
+	thousands := ","
+	if m.DecimalSeparator == "," {
+		thousands = "."
+	}
+	whole, fraction, hasFraction := strings.Cut(raw, m.DecimalSeparator)
+	groups := strings.Split(whole, thousands)
+	for _, g := range groups[1:] {
+		if len(g) != 3 || strings.Trim(g, "0123456789") != "" {
+			return 0, errThousands
+		}
+	}
+	s := strings.Join(groups, "")
+	if hasFraction {
+		s += "." + fraction
+	}
+	return money.Parse(s)
+}
+
+// ParseCSV reads a CSV statement with a header row. Errors name the line of
+// the file they were found on.
+func ParseCSV(r io.Reader, m Mapping) ([]Row, error) {
+	m = m.withDefaults()
+	if m.DecimalSeparator != "." && m.DecimalSeparator != "," {
+		return nil, errors.New(`decimal_separator must be "." or ","`)
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name string) int {
+		if i, ok := index[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return i
+		}
+		return -1
+	}
+	cols := struct{ date, amount, typ, currency, category, note int }{
+		column(m.Date), column(m.Amount), column(m.Type), column(m.Currency), column(m.Category), column(m.Note),
+	}
+	if cols.date < 0 {
+		return nil, fmt.Errorf("csv header has no %q column", m.Date)
+	}
+	if cols.amount < 0 {
+		return nil, fmt.Errorf("csv header has no %q column", m.Amount)
+	}
+
+	var rows []Row
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		if strings.Join(rec, "") == "" {
+			continue
+		}
+
+		date, err := time.Parse(m.DateLayout, field(cols.date))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: date must match %s", line, m.DateLayout)
+		}
+		amount, err := m.amount(field(cols.amount))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row := Row{
+			Date:     date,
+			Currency: field(cols.currency),
+			Category: field(cols.category),
+			Note:     field(cols.note),
+		}
+		row.Amount, row.Type = signed(amount)
+		if t := field(cols.typ); t != "" {
+			if row.Type, err = txtype.Parse(t); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
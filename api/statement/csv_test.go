@@ -0,0 +1,94 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSV(t *testing.T) {
+	t.Run("default mapping", func(t *testing.T) {
+		in := "date,amount,transaction_type,currency,category,note\n" +
+			"2024-05-18,120.50,expense,THB,Food,Lunch\n" +
+			"\n" +
+			"2024-05-19,30000,INCOME,,Salary,\n"
+
+		rows, err := ParseCSV(strings.NewReader(in), Mapping{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Row{
+			{Date: time.Date(2024, 5, 18, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("120.50"), Type: txtype.Expense, Currency: "THB", Category: "Food", Note: "Lunch"},
+			{Date: time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("30000"), Type: txtype.Income, Category: "Salary"},
+		}, rows)
+	})
+
+	t.Run("custom mapping takes the type from the sign", func(t *testing.T) {
+		in := "\ufeffPosted,Description,Value\n18/05/2024,Coffee,\"-1,250.00\"\n20/05/2024,Refund,50\n"
+		m := Mapping{Date: "Posted", Amount: "value", Note: "Description", DateLayout: "02/01/2006"}
+
+		rows, err := ParseCSV(strings.NewReader(in), m)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Row{
+			{Date: time.Date(2024, 5, 18, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("1250"), Type: txtype.Expense, Note: "Coffee"},
+			{Date: time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("50"), Type: txtype.Income, Note: "Refund"},
+		}, rows)
+	})
+
+	t.Run("separators", func(t *testing.T) {
+		tests := []struct {
+			raw     string
+			decimal string
+			want    string
+		}{
+			{"1,250.00", "", "1250.00"},
+			{"-1,234,567.5", ".", "1234567.50"},
+			{"12.50", "", "12.50"},
+			{"12,50", ",", "12.50"},
+			{"1.250,00", ",", "1250.00"},
+		}
+		for _, tc := range tests {
+			in := "date,amount\n2024-05-18,\"" + tc.raw + "\"\n"
+
+			rows, err := ParseCSV(strings.NewReader(in), Mapping{DecimalSeparator: tc.decimal})
+
+			if assert.NoError(t, err, tc.raw) {
+				assert.Equal(t, tc.want, rows[0].Amount.String(), tc.raw)
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty file", "", "csv file is empty"},
+		{"missing date column", "when,amount\n", `csv header has no "date" column`},
+		{"bad date", "date,amount\n2024-05-18,1\n18/05/2024,1\n", "line 3: date must match 2006-01-02"},
+		{"bad amount", "date,amount\n2024-05-18,1.234\n", "line 2: " + money.ErrPrecision.Error()},
+		{"bad type", "date,amount,transaction_type\n2024-05-18,1,transfer\n", "line 2: " + txtype.ErrInvalid.Error()},
+		{"decimal comma read as thousands", "date,amount\n2024-05-18,\"12,50\"\n", "line 2: " + errThousands.Error()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(tc.in), Mapping{})
+
+			assert.EqualError(t, err, tc.want)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for name, want := range map[string]string{"may.csv": FormatCSV, "MAY.OFX": FormatOFX, "may.qfx": FormatOFX} {
+		got, err := Format(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := Format("may.xlsx")
+	assert.ErrorIs(t, err, ErrFormat)
+}
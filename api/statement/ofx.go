@@ -0,0 +1,140 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+)
+
+var errOFXDate = errors.New("DTPOSTED must be an OFX date")
+
+// tag is an OFX element: an opening tag with the text that follows it, or a
+// closing tag.
+type tag struct {
+	name  string
+	value string
+	end   bool
+}
+
+// tags splits an OFX document into its elements. OFX 1.x is SGML where leaf
+// elements have no closing tag, and OFX 2.x is XML; reading the text up to the
+// next '<' handles both. Headers, processing instructions and comments are
+// skipped.
+func tags(doc string) []tag {
+	var out []tag
+	for _, piece := range strings.Split(doc, "<")[1:] {
+		name, value, ok := strings.Cut(piece, ">")
+		if !ok || strings.HasPrefix(name, "?") || strings.HasPrefix(name, "!") {
+			continue
+		}
+		name = strings.ToUpper(strings.TrimSpace(name))
+		t := tag{name: strings.TrimPrefix(name, "/"), end: strings.HasPrefix(name, "/")}
+		if !t.end {
+			t.value = html.UnescapeString(strings.TrimSpace(value))
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// ParseOFX reads the STMTTRN records of an OFX or QFX statement. The statement
+// currency (CURDEF) applies to every record after it. Errors name the record
+// they were found in, counting from 1.
+func ParseOFX(r io.Reader) ([]Row, error) {
+	doc, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	var fields map[string]string
+	var currency string
+	for _, t := range tags(string(doc)) {
+		switch {
+		case t.name == "CURDEF" && !t.end:
+			currency = t.value
+		case t.name == "STMTTRN" && !t.end:
+			fields = map[string]string{}
+		case t.name == "STMTTRN":
+			if fields == nil {
+				continue
+			}
+			row, err := ofxRow(fields)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %d: %w", len(rows)+1, err)
+			}
+			row.Currency = currency
+			rows = append(rows, row)
+			fields = nil
+		case fields != nil && !t.end:
+			fields[t.name] = t.value
+		}
+	}
+	if rows == nil && !strings.Contains(strings.ToUpper(string(doc)), "<OFX>") {
+		return nil, errors.New("file is not an OFX statement")
+	}
+	return rows, nil
+}
+
+func ofxRow(fields map[string]string) (Row, error) {
+	date, err := ofxDate(fields["DTPOSTED"])
+	if err != nil {
+		return Row{}, err
+	}
+	raw := fields["TRNAMT"]
+	if !strings.Contains(raw, ".") {
+		// some banks write a decimal comma
+		raw = strings.Replace(raw, ",", ".", 1)
+	}
+	amount, err := money.Parse(raw)
+	if err != nil {
+		return Row{}, err
+	}
+
+	note := fields["NAME"]
+	if memo := fields["MEMO"]; memo != "" && memo != note {
+		if note != "" {
+			note += " - "
+		}
+		note += memo
+	}
+	row := Row{Date: date, Note: note}
+	row.Amount, row.Type = signed(amount)
+	return row, nil
+}
+
+// ofxDate reads an OFX datetime such as 20240518, 20240518120000 or
+// 20240518120000.000[+7:ICT]. Without a zone OFX times are in UTC.
+func ofxDate(s string) (time.Time, error) {
+	s, zone, _ := strings.Cut(strings.TrimSpace(s), "[")
+	s, _, _ = strings.Cut(s, ".")
+
+	loc := time.UTC
+	if zone != "" {
+		offset, _, _ := strings.Cut(strings.TrimSuffix(zone, "]"), ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil {
+			return time.Time{}, errOFXDate
+		}
+		loc = time.FixedZone("", int(hours*3600))
+	}
+
+	layout := "20060102150405"
+	switch len(s) {
+	case 8, 12, 14:
+		layout = layout[:len(s)]
+	default:
+		return time.Time{}, errOFXDate
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return time.Time{}, errOFXDate
+	}
+	return t, nil
+}
@@ -0,0 +1,83 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/stretchr/testify/assert"
+)
+
+const sgml = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>THB
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240518120000.000[+7:ICT]
+<TRNAMT>-120.50
+<FITID>1
+<NAME>7-Eleven
+<MEMO>Snacks &amp; water
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240519
+<TRNAMT>30000,00
+<FITID>2
+<NAME>Salary
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD</CURDEF>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20240518</DTPOSTED><TRNAMT>-9.99</TRNAMT><NAME>Books</NAME></STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	t.Run("sgml", func(t *testing.T) {
+		rows, err := ParseOFX(strings.NewReader(sgml))
+
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.True(t, rows[0].Date.Equal(time.Date(2024, 5, 18, 5, 0, 0, 0, time.UTC)))
+		assert.Equal(t, money.MustParse("120.50"), rows[0].Amount)
+		assert.Equal(t, txtype.Expense, rows[0].Type)
+		assert.Equal(t, "THB", rows[0].Currency)
+		assert.Equal(t, "7-Eleven - Snacks & water", rows[0].Note)
+		assert.Equal(t, Row{Date: time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("30000"), Type: txtype.Income, Currency: "THB", Note: "Salary"}, rows[1])
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		rows, err := ParseOFX(strings.NewReader(xml))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Row{{Date: time.Date(2024, 5, 18, 0, 0, 0, 0, time.UTC), Amount: money.MustParse("9.99"), Type: txtype.Expense, Currency: "USD", Note: "Books"}}, rows)
+	})
+
+	t.Run("bad date", func(t *testing.T) {
+		_, err := ParseOFX(strings.NewReader(strings.Replace(sgml, "20240519", "2024-05-19", 1)))
+
+		assert.EqualError(t, err, "transaction 2: DTPOSTED must be an OFX date")
+	})
+
+	t.Run("not ofx", func(t *testing.T) {
+		_, err := ParseOFX(strings.NewReader("date,amount\n"))
+
+		assert.EqualError(t, err, "file is not an OFX statement")
+	})
+}
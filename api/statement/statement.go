@@ -0,0 +1,53 @@
+// Package statement reads bank statements into transaction rows. It knows
+// nothing about spenders or the database; callers validate and store rows.
+package statement
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+)
+
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+)
+
+var ErrFormat = errors.New("format must be csv or ofx")
+
+// Row is one transaction read from a statement. Amount is never negative; the
+// sign of the statement amount decides Type unless the statement names it.
+// Currency and Category are empty when the statement does not have them.
+type Row struct {
+	Date     time.Time
+	Amount   money.Amount
+	Type     txtype.TransactionType
+	Currency string
+	Category string
+	Note     string
+}
+
+// Format returns the statement format of a file name by its extension.
+// QFX files are OFX files under another name.
+func Format(filename string) (string, error) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ofx", ".qfx":
+		return FormatOFX, nil
+	}
+	return "", ErrFormat
+}
+
+// signed turns a statement amount into a positive amount and its type:
+// money going out is negative on a bank statement.
+func signed(a money.Amount) (money.Amount, txtype.TransactionType) {
+	if a < 0 {
+		return -a, txtype.Expense
+	}
+	return a, txtype.Income
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps objects as files under a directory.
+type Local struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocal stores objects under dir. Locations are under publicURL when it is
+// set, or file URLs otherwise.
+func NewLocal(dir, publicURL string) *Local {
+	return &Local{dir: dir, publicURL: publicURL}
+}
+
+func (l *Local) path(key string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) location(key string) string {
+	if l.publicURL != "" {
+		return location(l.publicURL, key)
+	}
+	abs, err := filepath.Abs(filepath.Join(l.dir, filepath.FromSlash(key)))
+	if err != nil {
+		abs = filepath.Join(l.dir, filepath.FromSlash(key))
+	}
+	return "file://" + filepath.ToSlash(abs)
+}
+
+// Put writes to a temporary file next to the object and renames it into
+// place, so a failed upload never leaves a partial object behind.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+	return l.location(key), nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	key, err := Key("slips/", strings.NewReader("hello"), ".png")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "slips/2c/2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824.png", key)
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l := NewLocal(dir, "")
+
+	t.Run("put and get", func(t *testing.T) {
+		loc, err := l.Put(ctx, "slips/ab/abc.png", strings.NewReader("image"), 5, "image/png")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, "slips", "ab", "abc.png")), loc)
+		rc, err := l.Get(ctx, "slips/ab/abc.png")
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "image", string(b))
+	})
+
+	t.Run("a short body leaves nothing behind", func(t *testing.T) {
+		_, err := l.Put(ctx, "slips/cd/cde.png", strings.NewReader("ima"), 5, "image/png")
+
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		entries, _ := os.ReadDir(filepath.Join(dir, "slips", "cd"))
+		assert.Empty(t, entries)
+	})
+
+	t.Run("public url", func(t *testing.T) {
+		loc, err := NewLocal(dir, "https://cdn.example.com/").Put(ctx, "a/b.png", strings.NewReader(""), 0, "image/png")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://cdn.example.com/a/b.png", loc)
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		_, err := l.Get(ctx, "slips/00/none.png")
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("keys cannot escape the directory", func(t *testing.T) {
+		for _, key := range []string{"../etc/passwd", "/etc/passwd", "a//b", "a/./b", `a\..\b`, ""} {
+			_, err := l.Put(ctx, key, strings.NewReader(""), 0, "")
+			assert.ErrorIs(t, err, ErrInvalidKey, key)
+		}
+	})
+}
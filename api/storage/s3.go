@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3-compatible bucket.
+type S3Config struct {
+	// Endpoint is the base URL of the service, such as
+	// https://s3.ap-southeast-1.amazonaws.com or http://localhost:9000.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle puts the bucket in the path instead of the host name, which
+	// MinIO and most other stand-ins need.
+	PathStyle bool
+	PublicURL string
+}
+
+// S3 keeps objects in an S3-compatible bucket, talking to its REST API
+// directly with SigV4-signed requests.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	creds    credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.New("s3 endpoint must be an absolute URL")
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3{
+		cfg:      cfg,
+		endpoint: endpoint,
+		creds:    credentials{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: cfg.Region, service: "s3"},
+		client:   &http.Client{Timeout: time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL addresses key in path style, endpoint/bucket/key, or in virtual
+// host style, bucket.endpoint/key.
+func (s *S3) objectURL(key string) string {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		u.Path = base + "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	return u.String()
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType)
+	}
+	// the body is a stream we do not want to read twice just to hash it
+	req.Header.Set(headerAmzContent, unsignedPayload)
+	s.creds.sign(req, unsignedPayload, s.now())
+	return s.client.Do(req)
+}
+
+// statusError reads the start of an S3 error response into an error.
+func statusError(op string, res *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3 %s: %s: %s", op, res.Status, strings.TrimSpace(string(msg)))
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error) {
+	res, err := s.do(ctx, http.MethodPut, key, r, size, contentType)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", statusError("put", res)
+	}
+	if s.cfg.PublicURL != "" {
+		return location(s.cfg.PublicURL, key), nil
+	}
+	return s.objectURL(key), nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, statusError("get", res)
+	}
+	return res.Body, nil
+}
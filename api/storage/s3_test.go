@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// vectors of the AWS SigV4 test suite
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-empty-query-key", "https://example.amazonaws.com/?Param1=value1", "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-vanilla-query-order-value", "https://example.amazonaws.com/?Param1=value2&Param1=value1", "5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694"},
+		{"get-vanilla-query-unreserved", "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", "9c3e54bfcdf0b19771a7f523ee5669cdf59bc7cc0884027167c21bb143a40197"},
+		{"get-vanilla-utf8-query", "https://example.amazonaws.com/?\u1234=bar", "2cdec8eed098649ff3a119c94853b13c643bcf08f8b0a1d91e12c9027818dd04"},
+		{"get-space", "https://example.amazonaws.com/example%20space/", "652487583200325589f1fba4c7e578f72c47cb61beeca81406b39ddec1366741"},
+		{"get-unreserved", "https://example.amazonaws.com/-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", "07ef7494c76fa4850883e2b006601f940f8a34d404d0cfa977f52a65bbf5f24f"},
+		{"get-utf8", "https://example.amazonaws.com/\u1234", "8318018e0b0f223aa2bbf98705b62bb787dc9c0e678f255a891fd03141be5d85"},
+	}
+	cr := credentials{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", region: "us-east-1", service: "service"}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			assert.NoError(t, err)
+
+			cr.sign(req, sha256Hex(""), time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+			assert.Equal(t, "20150830T123600Z", req.Header.Get(headerAmzDate))
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+				"SignedHeaders=host;x-amz-date, Signature="+tc.signature, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	q, _ := url.ParseQuery("a=2&a-b=1&a=1&c=x+y")
+
+	assert.Equal(t, "a=1&a=2&a-b=1&c=x%20y", canonicalQuery(q))
+}
+
+func TestCanonicalURI(t *testing.T) {
+	assert.Equal(t, "/", canonicalURI(""))
+	assert.Equal(t, "/bucket/slips/a%2Bb%3D%40%21.png", canonicalURI("/bucket/slips/a+b=@!.png"))
+}
+
+// fakeS3 is a MinIO-style stand-in: a path-style bucket in memory that
+// checks every request is signed with its credentials.
+type fakeS3 struct {
+	creds   credentials
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	date, _ := time.Parse(amzDateLayout, r.Header.Get(headerAmzDate))
+	check := r.Clone(context.Background())
+	check.Host = r.Host
+	check.URL.Host = r.Host
+	check.Header.Del("Authorization")
+	f.creds.sign(check, r.Header.Get(headerAmzContent), date)
+	if got := r.Header.Get("Authorization"); got == "" || got != check.Header.Get("Authorization") {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(b)
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	}
+}
+
+func TestS3(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{
+		creds:   credentials{accessKey: "minio", secretKey: "minio-secret", region: "us-east-1", service: "s3"},
+		objects: map[string]string{},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	cfg := S3Config{Endpoint: srv.URL, Bucket: "slips", AccessKey: "minio", SecretKey: "minio-secret", PathStyle: true}
+	s, err := NewS3(cfg)
+	assert.NoError(t, err)
+
+	t.Run("put and get", func(t *testing.T) {
+		loc, err := s.Put(ctx, "slips/ab/abc.png", strings.NewReader("image"), 5, "image/png")
+
+		assert.NoError(t, err)
+		assert.Equal(t, srv.URL+"/slips/slips/ab/abc.png", loc)
+		assert.Equal(t, "image", fake.objects["/slips/slips/ab/abc.png"])
+		rc, err := s.Get(ctx, "slips/ab/abc.png")
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "image", string(b))
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		_, err := s.Get(ctx, "slips/00/none.png")
+
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		bad := cfg
+		bad.SecretKey = "guess"
+		s, _ := NewS3(bad)
+
+		_, err := s.Put(ctx, "slips/ab/abc.png", strings.NewReader("image"), 5, "image/png")
+
+		assert.ErrorContains(t, err, "403 Forbidden")
+	})
+
+	t.Run("virtual host style url", func(t *testing.T) {
+		s, _ := NewS3(S3Config{Endpoint: "https://s3.ap-southeast-1.amazonaws.com", Bucket: "hongjot"})
+
+		assert.Equal(t, "https://hongjot.s3.ap-southeast-1.amazonaws.com/a/b.png", s.objectURL("a/b.png"))
+	})
+
+	t.Run("bad config", func(t *testing.T) {
+		_, err := NewS3(S3Config{Endpoint: "localhost:9000", Bucket: "b"})
+		assert.Error(t, err)
+		_, err = NewS3(S3Config{Endpoint: "http://localhost:9000"})
+		assert.Error(t, err)
+	})
+}
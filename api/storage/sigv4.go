@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	amzDateLayout    = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	headerAmzDate    = "X-Amz-Date"
+	headerAmzContent = "X-Amz-Content-Sha256"
+)
+
+// credentials sign requests with AWS Signature Version 4, the S3 way: paths
+// are encoded once rather than twice. TestSign runs the vectors of the AWS
+// test suite that S3 signs alike.
+type credentials struct {
+	accessKey string
+	secretKey string
+	region    string
+	service   string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// sign sets the X-Amz-Date and Authorization headers of req for the time t.
+// payloadHash is the hex SHA-256 of the body or UNSIGNED-PAYLOAD. The host,
+// Content-Type and every X-Amz-* header are signed.
+func (cr credentials) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format(amzDateLayout)
+	req.Header.Set(headerAmzDate, amzDate)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{amzDate[:8], cr.region, cr.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cr.secretKey), amzDate[:8])
+	key = hmacSHA256(key, cr.region)
+	key = hmacSHA256(key, cr.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, cr.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalURI encodes every segment of the path once, as S3 expects: all
+// but the unreserved characters are escaped, whatever form the URL kept.
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery encodes the query the way SigV4 expects, with spaces as %20,
+// and sorts it by encoded name and then by encoded value.
+func canonicalQuery(q url.Values) string {
+	var pairs [][2]string
+	for name, values := range q {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{escape(name), escape(v)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// escape percent-encodes every byte but the unreserved characters of RFC
+// 3986.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
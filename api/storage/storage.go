@@ -0,0 +1,86 @@
+// Package storage keeps uploaded files in a pluggable object store: a local
+// directory or an S3-compatible bucket such as AWS S3 or MinIO.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage stores objects under keys made by Key.
+type Storage interface {
+	// Put stores the size bytes read from r under key and returns the
+	// location the object can be fetched from.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error)
+	// Get opens the object stored under key, or returns ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// New returns the backend selected by cfg.Backend.
+func New(cfg config.Storage) (Storage, error) {
+	switch cfg.Backend {
+	case BackendLocal, "":
+		return NewLocal(cfg.LocalDir, cfg.PublicURL), nil
+	case BackendS3:
+		return NewS3(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+			PublicURL: cfg.PublicURL,
+		})
+	}
+	return nil, fmt.Errorf("storage backend must be %s or %s", BackendLocal, BackendS3)
+}
+
+// Key returns the content-addressed key of the bytes read from r: the prefix,
+// the first two hex digits of their SHA-256 to spread objects over
+// directories, then the whole digest and ext. The same content always gets
+// the same key, so storing it again is harmless and clients never choose
+// where their bytes land.
+func Key(prefix string, r io.Reader, ext string) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	return strings.TrimSuffix(prefix, "/") + "/" + sum[:2] + "/" + sum + ext, nil
+}
+
+// validKey rejects keys that could escape the store, such as absolute paths
+// or ones with . or .. segments.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// location joins the public base URL and key.
+func location(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
@@ -0,0 +1,136 @@
+package summary
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrInvalidDate  = errors.New("from and to must be in YYYY-MM-DD format")
+	ErrInvalidRange = errors.New("to must not be before from")
+)
+
+type Balance struct {
+	TotalIncome    money.Amount `json:"total_income"`
+	TotalExpenses  money.Amount `json:"total_expenses"`
+	CurrentBalance money.Amount `json:"current_balance"`
+}
+
+// DateRange is an optional, inclusive window of calendar days. A nil bound is
+// open ended.
+type DateRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// Args returns the bounds as query arguments: the start of From and the start
+// of the day after To, or nil for an open bound.
+func (r DateRange) Args() (any, any) {
+	var from, until any
+	if r.From != nil {
+		from = *r.From
+	}
+	if r.To != nil {
+		until = r.To.AddDate(0, 0, 1)
+	}
+	return from, until
+}
+
+const (
+	balanceSQL = `SELECT
+	    COALESCE(SUM(CASE WHEN transaction_type = $1 THEN base_amount ELSE 0 END), 0) AS total_income,
+	    COALESCE(SUM(CASE WHEN transaction_type = $2 THEN base_amount ELSE 0 END), 0) AS total_expenses
+	FROM
+	    transaction_base
+	WHERE
+	    spender_id = $3
+	    AND ($4::timestamptz IS NULL OR date >= $4)
+	    AND ($5::timestamptz IS NULL OR date < $5);`
+)
+
+func parseDate(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	d, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return nil, ErrInvalidDate
+	}
+	return &d, nil
+}
+
+// ParseDateRange reads from and to dates in YYYY-MM-DD format. Either may be
+// empty for an open bound.
+func ParseDateRange(from, to string) (DateRange, error) {
+	f, err := parseDate(from)
+	if err != nil {
+		return DateRange{}, err
+	}
+	t, err := parseDate(to)
+	if err != nil {
+		return DateRange{}, err
+	}
+	if f != nil && t != nil && t.Before(*f) {
+		return DateRange{}, ErrInvalidRange
+	}
+	return DateRange{From: f, To: t}, nil
+}
+
+func parseDateRange(c echo.Context) (DateRange, error) {
+	return ParseDateRange(c.QueryParam("from"), c.QueryParam("to"))
+}
+
+// Totals returns the spender's income and expenses over period, converted to
+// the spender's base currency, and the balance between them.
+func Totals(ctx context.Context, db *sql.DB, spenderID int, period DateRange) (Balance, error) {
+	stmt, err := db.PrepareContext(ctx, balanceSQL)
+	if err != nil {
+		return Balance{}, err
+	}
+	defer stmt.Close()
+
+	from, until := period.Args()
+	var b Balance
+	err = stmt.QueryRowContext(ctx, txtype.Income, txtype.Expense, spenderID, from, until).Scan(&b.TotalIncome, &b.TotalExpenses)
+	if err != nil {
+		return Balance{}, err
+	}
+	b.CurrentBalance = b.TotalIncome - b.TotalExpenses
+	return b, nil
+}
+
+func (h *handler) GetBalanceHandler(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var spender Spender
+	err := c.Bind(&spender)
+	if err != nil {
+		logger.Error(ErrInvalidSpender.Error(), zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: ErrInvalidSpender.Error()})
+	}
+
+	period, err := parseDateRange(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	b, err := Totals(ctx, h.db, spender.ID, period)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+
+	return c.JSON(http.StatusOK, b)
+}
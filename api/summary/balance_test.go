@@ -0,0 +1,121 @@
+package summary
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBalanceHandler(t *testing.T) {
+	newContext := func(query, id string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/spenders/:id/balance")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("invalid spender id expect 400", func(t *testing.T) {
+		c, rec := newContext("", "not_int")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetBalanceHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("invalid date expect 400", func(t *testing.T) {
+		c, rec := newContext("?from=01-05-2024", "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetBalanceHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "from and to must be in YYYY-MM-DD format"}`, rec.Body.String())
+	})
+
+	t.Run("to before from expect 400", func(t *testing.T) {
+		c, rec := newContext("?from=2024-05-02&to=2024-05-01", "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetBalanceHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("get balance of whole history succesfully", func(t *testing.T) {
+		c, rec := newContext("", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"total_income", "total_expenses"}).AddRow(2000, 1500.5)
+		mock.ExpectPrepare(balanceSQL).ExpectQuery().WithArgs(txtype.Income, txtype.Expense, 1, nil, nil).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBalanceHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total_income": 2000, "total_expenses": 1500.5, "current_balance": 499.5}`, rec.Body.String())
+	})
+
+	t.Run("get balance within date range succesfully", func(t *testing.T) {
+		c, rec := newContext("?from=2024-05-01&to=2024-05-31", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+		until := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows([]string{"total_income", "total_expenses"}).AddRow(1000, 1500)
+		mock.ExpectPrepare(balanceSQL).ExpectQuery().WithArgs(txtype.Income, txtype.Expense, 1, from, until).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBalanceHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total_income": 1000, "total_expenses": 1500, "current_balance": -500}`, rec.Body.String())
+	})
+
+	t.Run("prepare error", func(t *testing.T) {
+		c, rec := newContext("", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare(balanceSQL).WillReturnError(assert.AnError)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBalanceHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		c, rec := newContext("", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare(balanceSQL).ExpectQuery().WillReturnError(assert.AnError)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetBalanceHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
@@ -0,0 +1,151 @@
+package summary
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidTop = errors.New("top must be a positive integer")
+
+type CategoryRawData struct {
+	Category  string
+	SumAmount money.Amount
+	Count     int
+}
+
+type CategoryTotal struct {
+	Category     string       `json:"category"`
+	Total        money.Amount `json:"total_amount"`
+	Count        int          `json:"count_transaction"`
+	SharePercent float64      `json:"share_percent"`
+}
+
+type CategorySummary struct {
+	Total      money.Amount    `json:"total_amount"`
+	Categories []CategoryTotal `json:"categories"`
+}
+
+// categorySQL and sumSQL only count transactions that SUM adds up: those
+// without a known exchange rate have a NULL base_amount and are left out of
+// both, so a category with nothing converted has a NULL total and sorts last.
+const (
+	categorySQL = `SELECT
+	    category,
+	    SUM(base_amount) AS total_amount,
+	    COUNT(base_amount) AS record_count
+	FROM
+	    transaction_base
+	WHERE
+	    transaction_type = $1 AND spender_id = $2
+	    AND ($3::timestamptz IS NULL OR date >= $3)
+	    AND ($4::timestamptz IS NULL OR date < $4)
+	GROUP BY
+	    category
+	ORDER BY
+	    total_amount DESC NULLS LAST, category;`
+)
+
+// categorySummary expects data sorted by total descending. Shares are
+// computed against every category even when only the top ones are returned.
+func categorySummary(data []CategoryRawData, top int) CategorySummary {
+	var total money.Amount
+	for _, d := range data {
+		total += d.SumAmount
+	}
+
+	if top > 0 && top < len(data) {
+		data = data[:top]
+	}
+	categories := make([]CategoryTotal, 0, len(data))
+	for _, d := range data {
+		var share float64
+		if total != 0 {
+			share = math.Round(float64(d.SumAmount)/float64(total)*10000) / 100
+		}
+		categories = append(categories, CategoryTotal{
+			Category:     d.Category,
+			Total:        d.SumAmount,
+			Count:        d.Count,
+			SharePercent: share,
+		})
+	}
+
+	return CategorySummary{Total: total, Categories: categories}
+}
+
+func parseTop(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	top, err := strconv.Atoi(raw)
+	if err != nil || top < 1 {
+		return 0, ErrInvalidTop
+	}
+	return top, nil
+}
+
+func processCategoryRequest(c echo.Context, db *sql.DB, tnxType txtype.TransactionType) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	var spender Spender
+	err := c.Bind(&spender)
+	if err != nil {
+		logger.Error(ErrInvalidSpender.Error(), zap.Error(err))
+		return c.JSON(http.StatusBadRequest, Err{Message: ErrInvalidSpender.Error()})
+	}
+
+	period, err := parseDateRange(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	top, err := parseTop(c.QueryParam("top"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	stmt, err := db.PrepareContext(ctx, categorySQL)
+	if err != nil {
+		logger.Error("prepare statement error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "prepare statement error"})
+	}
+	defer stmt.Close()
+
+	from, until := period.Args()
+	rows, err := stmt.QueryContext(ctx, tnxType, spender.ID, from, until)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
+	}
+	defer rows.Close()
+
+	var raws []CategoryRawData
+	for rows.Next() {
+		var raw CategoryRawData
+		err := rows.Scan(&raw.Category, &raw.SumAmount, &raw.Count)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
+		}
+		raws = append(raws, raw)
+	}
+
+	return c.JSON(http.StatusOK, categorySummary(raws, top))
+}
+
+func (h *handler) GetExpenseCategorySummaryHandler(c echo.Context) error {
+	return processCategoryRequest(c, h.db, txtype.Expense)
+}
+
+func (h *handler) GetIncomeCategorySummaryHandler(c echo.Context) error {
+	return processCategoryRequest(c, h.db, txtype.Income)
+}
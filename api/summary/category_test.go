@@ -0,0 +1,170 @@
+package summary
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategorySummary(t *testing.T) {
+	data := []CategoryRawData{
+		{Category: "Food", SumAmount: 500, Count: 5},
+		{Category: "Transport", SumAmount: 300, Count: 3},
+		{Category: "Other", SumAmount: 200, Count: 1},
+	}
+
+	testCases := []struct {
+		name string
+		data []CategoryRawData
+		top  int
+		want CategorySummary
+	}{
+		{
+			name: "empty data",
+			data: nil,
+			want: CategorySummary{Total: 0, Categories: []CategoryTotal{}},
+		},
+		{
+			name: "all categories",
+			data: data,
+			want: CategorySummary{Total: 1000, Categories: []CategoryTotal{
+				{Category: "Food", Total: 500, Count: 5, SharePercent: 50},
+				{Category: "Transport", Total: 300, Count: 3, SharePercent: 30},
+				{Category: "Other", Total: 200, Count: 1, SharePercent: 20},
+			}},
+		},
+		{
+			name: "top categories keep share of overall total",
+			data: data,
+			top:  2,
+			want: CategorySummary{Total: 1000, Categories: []CategoryTotal{
+				{Category: "Food", Total: 500, Count: 5, SharePercent: 50},
+				{Category: "Transport", Total: 300, Count: 3, SharePercent: 30},
+			}},
+		},
+		{
+			name: "share is rounded to two decimals",
+			data: []CategoryRawData{{Category: "A", SumAmount: 1, Count: 1}, {Category: "B", SumAmount: 2, Count: 1}},
+			want: CategorySummary{Total: 3, Categories: []CategoryTotal{
+				{Category: "A", Total: 1, Count: 1, SharePercent: 33.33},
+				{Category: "B", Total: 2, Count: 1, SharePercent: 66.67},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, categorySummary(tc.data, tc.top))
+		})
+	}
+}
+
+func TestGetCategorySummaryHandler(t *testing.T) {
+	newContext := func(query, id string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/spenders/:id/expenses/summary/categories")
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return c, rec
+	}
+
+	t.Run("invalid spender id expect 400", func(t *testing.T) {
+		c, rec := newContext("", "not_int")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetExpenseCategorySummaryHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("invalid top expect 400", func(t *testing.T) {
+		c, rec := newContext("?top=0", "1")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetExpenseCategorySummaryHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "top must be a positive integer"}`, rec.Body.String())
+	})
+
+	t.Run("get expense categories succesfully", func(t *testing.T) {
+		c, rec := newContext("?from=2024-05-01&to=2024-05-31&top=1", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+		until := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows([]string{"category", "total_amount", "record_count"}).
+			AddRow("Food", 750, 3).
+			AddRow("Transport", 250, 2)
+		mock.ExpectPrepare(categorySQL).ExpectQuery().WithArgs(txtype.Expense, 1, from, until).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetExpenseCategorySummaryHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total_amount": 1000, "categories": [
+			{"category": "Food", "total_amount": 750, "count_transaction": 3, "share_percent": 75}]}`, rec.Body.String())
+	})
+
+	t.Run("get income categories succesfully", func(t *testing.T) {
+		c, rec := newContext("", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"category", "total_amount", "record_count"}).AddRow("Salary", 3000, 1)
+		mock.ExpectPrepare(categorySQL).ExpectQuery().WithArgs(txtype.Income, 1, nil, nil).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetIncomeCategorySummaryHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total_amount": 3000, "categories": [
+			{"category": "Salary", "total_amount": 3000, "count_transaction": 1, "share_percent": 100}]}`, rec.Body.String())
+	})
+
+	t.Run("prepare error", func(t *testing.T) {
+		c, rec := newContext("", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectPrepare(categorySQL).WillReturnError(assert.AnError)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetExpenseCategorySummaryHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		c, rec := newContext("", "1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		rows := sqlmock.NewRows([]string{"category", "total_amount"}).AddRow("Food", 750)
+		mock.ExpectPrepare(categorySQL).ExpectQuery().WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetExpenseCategorySummaryHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
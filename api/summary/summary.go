@@ -4,19 +4,25 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
 	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
 const (
-	typeExpense = "expense"
-	typeIncome  = "income"
+	granularityDay   = "day"
+	granularityWeek  = "week"
+	granularityMonth = "month"
+	granularityYear  = "year"
 )
 
 var (
-	ErrInvalidSpender = errors.New("invalid spender")
+	ErrInvalidSpender     = errors.New("invalid spender")
+	ErrInvalidGranularity = errors.New("granularity must be one of day, week, month or year")
 )
 
 type Err struct {
@@ -27,16 +33,27 @@ type Spender struct {
 	ID int `param:"id"`
 }
 
+// RawData is one bucket of sumSQL. FirstDay and LastDay are the dates of the
+// earliest and latest transaction inside the bucket.
 type RawData struct {
 	Date          string
-	SumAmount     float64
+	SumAmount     money.Amount
 	CountExpenses int
+	FirstDay      string
+	LastDay       string
+}
+
+type Bucket struct {
+	Date  string       `json:"date"`
+	Total money.Amount `json:"total_amount"`
+	Count int          `json:"count_transaction"`
 }
 
 type Summary struct {
-	Total   float64 `json:"total_amount"`
-	Average float64 `json:"average_per_day"`
-	Count   int     `json:"count_transaction"`
+	Total   money.Amount `json:"total_amount"`
+	Average money.Amount `json:"average_per_day"`
+	Count   int          `json:"count_transaction"`
+	Series  []Bucket     `json:"series"`
 }
 
 type handler struct {
@@ -46,43 +63,82 @@ type handler struct {
 
 const (
 	sumSQL = `SELECT
-	    date_trunc('day', date)::date AS transaction_date,
-	    SUM(amount) AS total_amount,
-	    COUNT(*) AS record_count
+	    to_char(date_trunc($3, date), 'YYYY-MM-DD') AS bucket,
+	    SUM(base_amount) AS total_amount,
+	    COUNT(base_amount) AS record_count,
+	    to_char(MIN(date), 'YYYY-MM-DD') AS first_day,
+	    to_char(MAX(date), 'YYYY-MM-DD') AS last_day
 	FROM
-	    "transaction"
+	    transaction_base
 	WHERE
 	    transaction_type = $1 AND spender_id = $2
+	    AND ($4::timestamptz IS NULL OR date >= $4)
+	    AND ($5::timestamptz IS NULL OR date < $5)
 	GROUP BY
-	    date_trunc('day', date)::date
+	    bucket
 	ORDER BY
-	    transaction_date;`
+	    bucket;`
 )
 
 func New(cfg config.FeatureFlag, db *sql.DB) *handler {
 	return &handler{cfg, db}
 }
 
-func summary(data []RawData) Summary {
-	if len(data) == 0 {
-		return Summary{}
+// calendarDays counts the days of the requested window. An open bound falls
+// back to the first or last transaction day found in data.
+func calendarDays(period DateRange, data []RawData) int {
+	start, end := period.From, period.To
+	for _, d := range data {
+		first, err := time.Parse(dateLayout, d.FirstDay)
+		if err == nil && period.From == nil && (start == nil || first.Before(*start)) {
+			start = &first
+		}
+		last, err := time.Parse(dateLayout, d.LastDay)
+		if err == nil && period.To == nil && (end == nil || last.After(*end)) {
+			end = &last
+		}
 	}
+	if start == nil || end == nil || end.Before(*start) {
+		return 0
+	}
+	return int(end.Sub(*start).Hours()/24) + 1
+}
 
-	var total float64
+func summary(data []RawData, days int) Summary {
+	series := make([]Bucket, 0, len(data))
+	var total money.Amount
 	var count int
 	for _, d := range data {
 		total += d.SumAmount
 		count += d.CountExpenses
+		series = append(series, Bucket{Date: d.Date, Total: d.SumAmount, Count: d.CountExpenses})
+	}
+
+	var average money.Amount
+	if days > 0 {
+		average = total.Div(days)
 	}
 
 	return Summary{
 		Total:   total,
-		Average: total / float64(len(data)),
+		Average: average,
 		Count:   count,
+		Series:  series,
 	}
 }
 
-func processSummaryRequest(c echo.Context, db *sql.DB, tnxType string) error {
+func parseGranularity(raw string) (string, error) {
+	switch raw {
+	case "":
+		return granularityDay, nil
+	case granularityDay, granularityWeek, granularityMonth, granularityYear:
+		return raw, nil
+	default:
+		return "", ErrInvalidGranularity
+	}
+}
+
+func processSummaryRequest(c echo.Context, db *sql.DB, tnxType txtype.TransactionType) error {
 	logger := mlog.L(c)
 	ctx := c.Request().Context()
 
@@ -93,13 +149,24 @@ func processSummaryRequest(c echo.Context, db *sql.DB, tnxType string) error {
 		return c.JSON(http.StatusBadRequest, Err{Message: ErrInvalidSpender.Error()})
 	}
 
+	period, err := parseDateRange(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	granularity, err := parseGranularity(c.QueryParam("granularity"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
 	stmt, err := db.PrepareContext(ctx, sumSQL)
 	if err != nil {
 		logger.Error("prepare statement error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, Err{Message: "prepare statement error"})
 	}
+	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, tnxType, spender.ID)
+	from, until := period.Args()
+	rows, err := stmt.QueryContext(ctx, tnxType, spender.ID, granularity, from, until)
 	if err != nil {
 		logger.Error("query error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, Err{Message: "query error"})
@@ -109,7 +176,7 @@ func processSummaryRequest(c echo.Context, db *sql.DB, tnxType string) error {
 	var raws []RawData
 	for rows.Next() {
 		var raw RawData
-		err := rows.Scan(&raw.Date, &raw.SumAmount, &raw.CountExpenses)
+		err := rows.Scan(&raw.Date, &raw.SumAmount, &raw.CountExpenses, &raw.FirstDay, &raw.LastDay)
 		if err != nil {
 			logger.Error("scan error", zap.Error(err))
 			return c.JSON(http.StatusInternalServerError, Err{Message: "scan error"})
@@ -117,13 +184,13 @@ func processSummaryRequest(c echo.Context, db *sql.DB, tnxType string) error {
 		raws = append(raws, raw)
 	}
 
-	return c.JSON(http.StatusOK, summary(raws))
+	return c.JSON(http.StatusOK, summary(raws, calendarDays(period, raws)))
 }
 
 func (h *handler) GetExpenseSummaryHandler(c echo.Context) error {
-	return processSummaryRequest(c, h.db, typeExpense)
+	return processSummaryRequest(c, h.db, txtype.Expense)
 }
 
 func (h *handler) GetIncomeSummaryHandler(c echo.Context) error {
-	return processSummaryRequest(c, h.db, typeIncome)
+	return processSummaryRequest(c, h.db, txtype.Income)
 }
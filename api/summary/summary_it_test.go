@@ -0,0 +1,91 @@
+//go:build integration
+
+package summary
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/migration"
+	"github.com/labstack/echo/v4"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryOwnershipIT(t *testing.T) {
+	db := getTestDatabaseFromConfig(t)
+	h := New(config.FeatureFlag{}, db)
+	owner := seedSpender(t, db, "summary-owner@jot.ok", auth.RoleSpender)
+	other := seedSpender(t, db, "summary-other@jot.ok", auth.RoleSpender)
+	admin := seedSpender(t, db, "summary-admin@jot.ok", auth.RoleAdmin)
+
+	newServer := func(caller auth.Spender) *echo.Echo {
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				auth.Set(c, caller)
+				return next(c)
+			}
+		})
+		o := auth.Owner("id")
+		e.GET("/spenders/:id/expenses/summary", h.GetExpenseSummaryHandler, o)
+		e.GET("/spenders/:id/incomes/summary", h.GetIncomeSummaryHandler, o)
+		return e
+	}
+
+	tests := []struct {
+		name   string
+		caller auth.Spender
+		path   string
+		want   int
+	}{
+		{"other spender cannot read expense summary", other, "expenses", http.StatusForbidden},
+		{"other spender cannot read income summary", other, "incomes", http.StatusForbidden},
+		{"owner can read expense summary", owner, "expenses", http.StatusOK},
+		{"admin can read income summary", admin, "incomes", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			url := fmt.Sprintf("/spenders/%d/%s/summary", owner.ID, tc.path)
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			rec := httptest.NewRecorder()
+
+			newServer(tc.caller).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.want, rec.Code)
+		})
+	}
+}
+
+func seedSpender(t *testing.T, db *sql.DB, email, role string) auth.Spender {
+	t.Helper()
+	sp := auth.Spender{Name: "HongJot", Email: email, Role: role}
+	err := db.QueryRow(`INSERT INTO spender (name, email, role) VALUES ($1, $2, $3) RETURNING id`, sp.Name, sp.Email, sp.Role).Scan(&sp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM spender WHERE id = $1`, sp.ID)
+	})
+	return sp
+}
+
+func getTestDatabaseFromConfig(t *testing.T) *sql.DB {
+	t.Helper()
+	cfg := config.Parse("DOCKER")
+	sql, err := sql.Open("postgres", cfg.PostgresURI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration.ApplyMigrations(sql)
+	t.Cleanup(func() {
+		sql.Query("DELETE FROM transaction")
+	})
+	return sql
+}
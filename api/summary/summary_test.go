@@ -3,49 +3,106 @@ package summary
 import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestSummary(t *testing.T) {
 	testCases := []struct {
 		name string
 		data []RawData
+		days int
 		want Summary
 	}{
 		{
 			name: "empty data",
 			data: []RawData{},
-			want: Summary{Total: 0, Average: 0, Count: 0},
+			days: 0,
+			want: Summary{Total: 0, Average: 0, Count: 0, Series: []Bucket{}},
 		},
 		{
 			name: "single data",
 			data: []RawData{
-				{SumAmount: 10, CountExpenses: 1},
+				{Date: "2024-04-03", SumAmount: 10, CountExpenses: 1},
 			},
-			want: Summary{Total: 10, Average: 10, Count: 1},
+			days: 1,
+			want: Summary{Total: 10, Average: 10, Count: 1, Series: []Bucket{
+				{Date: "2024-04-03", Total: 10, Count: 1},
+			}},
 		},
 		{
-			name: "multiple data",
+			name: "multiple data averaged over calendar days",
 			data: []RawData{
-				{SumAmount: 20, CountExpenses: 2},
-				{SumAmount: 30, CountExpenses: 3},
+				{Date: "2024-04-03", SumAmount: 20, CountExpenses: 2},
+				{Date: "2024-04-07", SumAmount: 30, CountExpenses: 3},
 			},
-			want: Summary{Total: 50, Average: 25, Count: 5},
+			days: 5,
+			want: Summary{Total: 50, Average: 10, Count: 5, Series: []Bucket{
+				{Date: "2024-04-03", Total: 20, Count: 2},
+				{Date: "2024-04-07", Total: 30, Count: 3},
+			}},
+		},
+		{
+			name: "cents add up exactly and the average is rounded to cents",
+			data: []RawData{
+				{Date: "2024-04-03", SumAmount: money.MustParse("0.10"), CountExpenses: 1},
+				{Date: "2024-04-04", SumAmount: money.MustParse("0.20"), CountExpenses: 1},
+				{Date: "2024-04-05", SumAmount: money.MustParse("0.70"), CountExpenses: 1},
+			},
+			days: 3,
+			want: Summary{Total: money.MustParse("1.00"), Average: money.MustParse("0.33"), Count: 3, Series: []Bucket{
+				{Date: "2024-04-03", Total: money.MustParse("0.10"), Count: 1},
+				{Date: "2024-04-04", Total: money.MustParse("0.20"), Count: 1},
+				{Date: "2024-04-05", Total: money.MustParse("0.70"), Count: 1},
+			}},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := summary(tc.data)
+			got := summary(tc.data, tc.days)
 			assert.Equal(t, tc.want, got)
 		})
 	}
 }
 
+func TestCalendarDays(t *testing.T) {
+	day := func(s string) *time.Time {
+		d, _ := time.Parse(dateLayout, s)
+		return &d
+	}
+	data := []RawData{
+		{FirstDay: "2024-04-03", LastDay: "2024-04-03"},
+		{FirstDay: "2024-04-05", LastDay: "2024-04-10"},
+	}
+
+	testCases := []struct {
+		name   string
+		period DateRange
+		data   []RawData
+		want   int
+	}{
+		{"no range uses first and last transaction", DateRange{}, data, 8},
+		{"full range ignores transactions", DateRange{From: day("2024-04-01"), To: day("2024-04-30")}, data, 30},
+		{"open end uses last transaction", DateRange{From: day("2024-04-01")}, data, 10},
+		{"open start uses first transaction", DateRange{To: day("2024-04-30")}, data, 28},
+		{"no data and no range", DateRange{}, nil, 0},
+		{"range without data", DateRange{From: day("2024-02-01"), To: day("2024-02-29")}, nil, 29},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, calendarDays(tc.period, tc.data))
+		})
+	}
+}
+
 func TestGetExpenseSummaryHandler(t *testing.T) {
 
 	t.Run("invalid spender id expect 400", func(t *testing.T) {
@@ -82,9 +139,9 @@ func TestGetExpenseSummaryHandler(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"transaction_date", "total_amount", "record_count"}).
-			AddRow("2024-04-03", 1000, 10).
-			AddRow("2024-04-04", 500, 5)
+		rows := sqlmock.NewRows([]string{"bucket", "total_amount", "record_count", "first_day", "last_day"}).
+			AddRow("2024-04-03", 1000, 10, "2024-04-03", "2024-04-03").
+			AddRow("2024-04-04", 500, 5, "2024-04-04", "2024-04-04")
 
 		mock.ExpectPrepare(sumSQL).ExpectQuery().WillReturnRows(rows)
 
@@ -93,7 +150,9 @@ func TestGetExpenseSummaryHandler(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.JSONEq(t, `{"total_amount": 1500, "average_per_day": 750, "count_transaction": 15}`, rec.Body.String())
+		assert.JSONEq(t, `{"total_amount": 1500, "average_per_day": 750, "count_transaction": 15, "series": [
+			{"date": "2024-04-03", "total_amount": 1000, "count_transaction": 10},
+			{"date": "2024-04-04", "total_amount": 500, "count_transaction": 5}]}`, rec.Body.String())
 	})
 
 	t.Run("prepare error", func(t *testing.T) {
@@ -169,6 +228,62 @@ func TestGetExpenseSummaryHandler(t *testing.T) {
 	})
 }
 
+func TestGetSummaryHandlerWithRange(t *testing.T) {
+	newContext := func(query string) (echo.Context, *httptest.ResponseRecorder) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/spenders/:id/expenses/summary")
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+		return c, rec
+	}
+
+	t.Run("monthly buckets averaged over the requested window", func(t *testing.T) {
+		c, rec := newContext("?from=2024-04-01&to=2024-05-31&granularity=month")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		from := time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+		until := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+		rows := sqlmock.NewRows([]string{"bucket", "total_amount", "record_count", "first_day", "last_day"}).
+			AddRow("2024-04-01", 3050, 10, "2024-04-03", "2024-04-28").
+			AddRow("2024-05-01", 3050, 5, "2024-05-02", "2024-05-20")
+		mock.ExpectPrepare(sumSQL).ExpectQuery().WithArgs(txtype.Expense, 1, "month", from, until).WillReturnRows(rows)
+
+		h := New(config.FeatureFlag{}, db)
+		err := h.GetExpenseSummaryHandler(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total_amount": 6100, "average_per_day": 100, "count_transaction": 15, "series": [
+			{"date": "2024-04-01", "total_amount": 3050, "count_transaction": 10},
+			{"date": "2024-05-01", "total_amount": 3050, "count_transaction": 5}]}`, rec.Body.String())
+	})
+
+	t.Run("invalid granularity expect 400", func(t *testing.T) {
+		c, rec := newContext("?granularity=hour")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetExpenseSummaryHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "granularity must be one of day, week, month or year"}`, rec.Body.String())
+	})
+
+	t.Run("invalid date range expect 400", func(t *testing.T) {
+		c, rec := newContext("?from=2024-05-01&to=2024-04-01")
+
+		h := New(config.FeatureFlag{}, nil)
+		_ = h.GetExpenseSummaryHandler(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "to must not be before from"}`, rec.Body.String())
+	})
+}
+
 func TestGetIncomeSummaryHandler(t *testing.T) {
 	t.Run("invalid spender id expect 400", func(t *testing.T) {
 		e := echo.New()
@@ -204,9 +319,9 @@ func TestGetIncomeSummaryHandler(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"transaction_date", "total_amount", "record_count"}).
-			AddRow("2024-04-03", 1000, 10).
-			AddRow("2024-04-04", 500, 5)
+		rows := sqlmock.NewRows([]string{"bucket", "total_amount", "record_count", "first_day", "last_day"}).
+			AddRow("2024-04-03", 1000, 10, "2024-04-03", "2024-04-03").
+			AddRow("2024-04-04", 500, 5, "2024-04-04", "2024-04-04")
 		mock.ExpectPrepare(sumSQL).ExpectQuery().WillReturnRows(rows)
 
 		h := New(config.FeatureFlag{}, db)
@@ -214,7 +329,9 @@ func TestGetIncomeSummaryHandler(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.JSONEq(t, `{"total_amount": 1500, "average_per_day": 750, "count_transaction": 15}`, rec.Body.String())
+		assert.JSONEq(t, `{"total_amount": 1500, "average_per_day": 750, "count_transaction": 15, "series": [
+			{"date": "2024-04-03", "total_amount": 1000, "count_transaction": 10},
+			{"date": "2024-04-04", "total_amount": 500, "count_transaction": 5}]}`, rec.Body.String())
 	})
 
 	t.Run("prepare error", func(t *testing.T) {
@@ -0,0 +1,43 @@
+package transaction
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursor points at the last transaction of a page. Pages are ordered by
+// (date, id) descending so the next page starts strictly after it.
+type cursor struct {
+	Date time.Time
+	ID   int
+}
+
+func (cur cursor) encode() string {
+	raw := cur.Date.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(cur.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, errInvalidCursor
+	}
+	date, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return cursor{}, errInvalidCursor
+	}
+	d, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return cursor{}, errInvalidCursor
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return cursor{}, errInvalidCursor
+	}
+	return cursor{Date: d, ID: n}, nil
+}
@@ -0,0 +1,133 @@
+package transaction
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/export"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/summary"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// flushEvery is how many exported rows are written between flushes of the
+// response, so a long export reaches the client while it is being read.
+const flushEvery = 500
+
+const exportWhere = `WHERE spender_id = $1 AND NOT draft AND ` + visible + `
+AND ($2::timestamptz IS NULL OR date >= $2) AND ($3::timestamptz IS NULL OR date < $3)`
+
+const exportStatement = `SELECT id, date, transaction_type, category, note, amount, currency FROM transaction
+` + exportWhere + `
+ORDER BY date, id;`
+
+// beyondLatin1Statement tells whether a category or note of the export has
+// a character past U+00FF, which a PDF statement cannot print.
+const beyondLatin1Statement = `SELECT EXISTS (SELECT 1 FROM transaction
+` + exportWhere + `
+AND (category ~ '[^\u0001-\u00ff]' OR note ~ '[^\u0001-\u00ff]'));`
+
+// statementTitle heads every page of a PDF statement.
+func statementTitle(spenderId int, period summary.DateRange) string {
+	from, to := "the beginning", "today"
+	if period.From != nil {
+		from = period.From.Format(dateLayout)
+	}
+	if period.To != nil {
+		to = period.To.Format(dateLayout)
+	}
+	return fmt.Sprintf("Statement of spender %d from %s to %s", spenderId, from, to)
+}
+
+// Export streams the spender's transactions from the from date to the to
+// date, both optional and inclusive, oldest first as a csv (the default),
+// xlsx or pdf file. A PDF statement ends with the totals of the summary
+// package for the same days. In csv and xlsx files a category or note that
+// starts like a formula (=, +, -, @) is prefixed with a quote so spreadsheets
+// show it as text. PDF statements use the standard Courier font, which only
+// covers Latin-1, so a PDF of Thai or other scripts is refused with 422
+// before anything is sent and the spender is pointed to csv or xlsx. Rows are written as they are read from the
+// database; once the first byte is sent a failure can only cut the file
+// short, so it is logged rather than returned to the client.
+func (h handler) Export(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderId, err := strconv.Atoi(c.Param("spenderId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: "invalid spender id"})
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	contentType := export.ContentType(format)
+	if contentType == "" {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: export.ErrFormat.Error()})
+	}
+	period, err := summary.ParseDateRange(c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+	}
+
+	from, until := period.Args()
+	st := export.Statement{Title: statementTitle(spenderId, period)}
+	if format == export.FormatPDF {
+		var beyond bool
+		if err := h.db.QueryRowContext(ctx, beyondLatin1Statement, spenderId, from, until).Scan(&beyond); err != nil {
+			logger.Error("query error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+		}
+		if beyond {
+			return c.JSON(http.StatusUnprocessableEntity, transactionError{Message: export.ErrUnencodable.Error()})
+		}
+		b, err := summary.Totals(ctx, h.db, spenderId, period)
+		if err != nil {
+			logger.Error("query error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+		}
+		st.Totals = export.Totals{Income: b.TotalIncome, Expenses: b.TotalExpenses, Balance: b.CurrentBalance}
+	}
+
+	rows, err := h.db.QueryContext(ctx, exportStatement, spenderId, from, until)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+	}
+	defer rows.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="transactions-%d.%s"`, spenderId, format))
+	res.WriteHeader(http.StatusOK)
+
+	w, err := export.New(format, res, st)
+	if err != nil {
+		logger.Error("export error", zap.Error(err))
+		return nil
+	}
+	for n := 1; rows.Next(); n++ {
+		var r export.Row
+		if err := rows.Scan(&r.ID, &r.Date, &r.Type, &r.Category, &r.Note, &r.Amount, &r.Currency); err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return nil
+		}
+		if err := w.Write(r); err != nil {
+			logger.Error("export error", zap.Error(err))
+			return nil
+		}
+		if n%flushEvery == 0 {
+			res.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("query error", zap.Error(err))
+		return nil
+	}
+	if err := w.Close(); err != nil {
+		logger.Error("export error", zap.Error(err))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package transaction
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var exportColumns = []string{"id", "date", "transaction_type", "category", "note", "amount", "currency"}
+
+func setupExportTest(query string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/spenders/1/transactions/export"+query, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("spenderId")
+	c.SetParamValues("1")
+	return c, rec
+}
+
+func TestExport(t *testing.T) {
+	t.Run("csv of the requested days", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupExportTest("?from=2024-05-01&to=2024-05-31")
+		date := time.Date(2024, 5, 18, 11, 51, 49, 0, time.UTC)
+		mock.ExpectQuery(exportStatement).
+			WithArgs(1, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)).
+			WillReturnRows(sqlmock.NewRows(exportColumns).
+				AddRow(1, date, "expense", "Food", "Lunch", "120.50", "THB").
+				AddRow(2, date, "income", "Salary", "", "30000", "THB"))
+
+		err := New(db).Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename="transactions-1.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, "date,transaction_type,category,note,amount,currency\n"+
+			"2024-05-18,expense,Food,Lunch,120.50,THB\n"+
+			"2024-05-18,income,Salary,,30000.00,THB\n", rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("pdf ends with the summary totals", func(t *testing.T) {
+		db, mock, _ := sqlmock.New()
+		defer db.Close()
+		c, rec := setupExportTest("?format=pdf")
+		mock.ExpectQuery(`SELECT EXISTS`).WithArgs(1, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectPrepare(`FROM\s+transaction_base`).ExpectQuery().
+			WithArgs("income", "expense", 1, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"total_income", "total_expenses"}).AddRow("1000", "250.25"))
+		mock.ExpectQuery(`FROM transaction`).WithArgs(1, nil, nil).
+			WillReturnRows(sqlmock.NewRows(exportColumns))
+
+		err := New(db).Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get(echo.HeaderContentType))
+		pdf := rec.Body.String()
+		assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4"))
+		assert.Contains(t, pdf, "(Statement of spender 1 from the beginning to today    page 1) '")
+		assert.Contains(t, pdf, "(  Expenses         250.25) '")
+		assert.Contains(t, pdf, "(  Balance          749.75) '")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("pdf refused when text is beyond Latin-1", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupExportTest("?format=pdf")
+		mock.ExpectQuery(beyondLatin1Statement).WithArgs(1, nil, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		err := New(db).Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.JSONEq(t, `{"message":"pdf statements only print Latin-1 text, export csv or xlsx instead"}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"unknown format", "?format=docx", `{"message":"format must be one of csv, xlsx or pdf"}`},
+		{"bad date", "?from=01/05/2024", `{"message":"from and to must be in YYYY-MM-DD format"}`},
+		{"reversed range", "?from=2024-05-02&to=2024-05-01", `{"message":"to must not be before from"}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, rec := setupExportTest(tc.query)
+
+			err := New(nil).Export(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, tc.want, rec.Body.String())
+		})
+	}
+
+	t.Run("query error before streaming", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupExportTest("?format=xlsx")
+		mock.ExpectQuery(exportStatement).WillReturnError(assert.AnError)
+
+		err := New(db).Export(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
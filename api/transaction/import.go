@@ -0,0 +1,250 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dberr"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/statement"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	maxImportSize = 5 << 20
+	// maxImportRequest leaves room for the other form fields around the file.
+	maxImportRequest = maxImportSize + 64<<10
+	maxImportRows    = 5000
+	defaultCategory  = "Uncategorized"
+)
+
+// existingStatement reads the transactions an import is deduplicated against.
+const existingStatement = `SELECT date, amount, note FROM transaction WHERE spender_id = $1 AND deleted_at IS NULL AND date >= $2 AND date < $3;`
+
+type importRow struct {
+	Date            time.Time              `json:"date"`
+	Amount          money.Amount           `json:"amount"`
+	Currency        string                 `json:"currency"`
+	Category        string                 `json:"category"`
+	TransactionType txtype.TransactionType `json:"transaction_type"`
+	Note            string                 `json:"note"`
+	Duplicate       bool                   `json:"duplicate"`
+}
+
+type importResponse struct {
+	DryRun     bool        `json:"dry_run"`
+	Imported   int         `json:"imported"`
+	Duplicates int         `json:"duplicates"`
+	Rows       []importRow `json:"rows"`
+}
+
+// dedupeKey identifies a transaction for deduplication. Statements only carry
+// the day, so the time of day is ignored.
+func dedupeKey(date time.Time, amount money.Amount, note string) string {
+	return date.UTC().Format(time.DateOnly) + "|" + amount.String() + "|" + note
+}
+
+// Import loads a CSV, OFX or QFX bank statement sent as the multipart field
+// "file" into the spender's transactions. A CSV file is read with the column
+// mapping in the optional JSON field "mapping", and rows without a category
+// get the one in the field "category". Rows matching an existing transaction
+// by day, amount and note are skipped. With dry_run=true the rows are only
+// returned for preview; otherwise all new rows are stored in one database
+// transaction, or none are.
+func (h *handler) Import(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	spenderId, err := strconv.Atoi(c.Param("spenderId"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: "invalid spender id"})
+	}
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxImportRequest)
+
+	file, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return c.JSON(http.StatusRequestEntityTooLarge, transactionError{Message: fmt.Sprintf("request must not exceed %d bytes", maxImportRequest)})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: "file is required"})
+	}
+	if file.Size > maxImportSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, transactionError{Message: fmt.Sprintf("file must not exceed %d bytes", maxImportSize)})
+	}
+	rows, err := parseStatement(file, c.FormValue("format"), c.FormValue("mapping"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+	}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: "statement has no transactions"})
+	}
+	if len(rows) > maxImportRows {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: fmt.Sprintf("statement must not have more than %d transactions", maxImportRows)})
+	}
+
+	category := strings.TrimSpace(c.FormValue("category"))
+	if category == "" {
+		category = defaultCategory
+	}
+	reqs := make([]request, len(rows))
+	for i, row := range rows {
+		req := request{Date: row.Date, Amount: row.Amount, Currency: row.Currency, Category: row.Category,
+			TransactionType: row.Type, Note: row.Note, SpenderId: spenderId}
+		if req.Category == "" {
+			req.Category = category
+		}
+		if reqs[i], err = validateTransaction(req); err != nil {
+			return c.JSON(http.StatusBadRequest, transactionError{Message: fmt.Sprintf("row %d: %v", i+1, err)})
+		}
+	}
+
+	res := importResponse{DryRun: dryRun, Rows: make([]importRow, len(reqs))}
+	existing, err := h.existing(ctx, spenderId, reqs)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+	}
+	for i, req := range reqs {
+		res.Rows[i] = importRow{Date: req.Date, Amount: req.Amount, Currency: req.Currency, Category: req.Category,
+			TransactionType: req.TransactionType, Note: req.Note}
+		// each existing transaction cancels out one row, so a statement
+		// with two equal payments on one day still imports the second
+		if key := dedupeKey(req.Date, req.Amount, req.Note); existing[key] > 0 {
+			existing[key]--
+			res.Rows[i].Duplicate = true
+			res.Duplicates++
+			continue
+		}
+		res.Imported++
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, res)
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error:", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	for i, req := range reqs {
+		if res.Rows[i].Duplicate {
+			continue
+		}
+		t := response{Date: req.Date, Amount: req.Amount, Category: req.Category, TransactionType: req.TransactionType,
+			Note: req.Note, SpenderId: req.SpenderId}
+		err = tx.QueryRowContext(ctx, insertStatement, req.Date, req.Amount, req.Category,
+			req.TransactionType, req.Note, req.ImageUrl, req.SpenderId, req.Currency).Scan(&t.Id, &t.Currency)
+		if dberr.IsForeignKey(err) {
+			return c.JSON(http.StatusNotFound, transactionError{Message: "spender not found"})
+		}
+		if dberr.IsInvalid(err) {
+			return c.JSON(http.StatusBadRequest, transactionError{Message: fmt.Sprintf("row %d: invalid transaction", i+1)})
+		}
+		if err != nil {
+			logger.Error("insert transaction into transaction table error:", zap.Error(err))
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		err = audit.Record(c, tx, audit.Entry{
+			Action:   audit.ActionCreate,
+			Entity:   audit.EntityTransaction,
+			EntityID: int64(t.Id),
+			After:    t,
+		})
+		if err != nil {
+			logger.Error("audit import error:", zap.Error(err))
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		res.Rows[i].Currency = t.Currency
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("commit transaction error:", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+// parseStatement reads an uploaded statement. The format comes from the
+// format field or, when that is empty, the file extension.
+func parseStatement(file *multipart.FileHeader, format, mapping string) ([]statement.Row, error) {
+	var err error
+	if format == "" {
+		format, err = statement.Format(file.Filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	r := io.LimitReader(src, maxImportSize)
+
+	switch strings.ToLower(format) {
+	case statement.FormatCSV:
+		var m statement.Mapping
+		if mapping != "" {
+			if err := json.Unmarshal([]byte(mapping), &m); err != nil {
+				return nil, errors.New("mapping must be a JSON object")
+			}
+		}
+		return statement.ParseCSV(r, m)
+	case statement.FormatOFX, "qfx":
+		return statement.ParseOFX(r)
+	}
+	return nil, statement.ErrFormat
+}
+
+// existing counts the spender's transactions by dedupe key over the days the
+// import covers.
+func (h *handler) existing(ctx context.Context, spenderId int, reqs []request) (map[string]int, error) {
+	from, to := reqs[0].Date, reqs[0].Date
+	for _, req := range reqs[1:] {
+		if req.Date.Before(from) {
+			from = req.Date
+		}
+		if req.Date.After(to) {
+			to = req.Date
+		}
+	}
+	// a day either side covers dates stored in another time zone
+	from = from.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	to = to.UTC().Truncate(24*time.Hour).AddDate(0, 0, 2)
+
+	rows, err := h.db.QueryContext(ctx, existingStatement, spenderId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var date time.Time
+		var amount money.Amount
+		var note sql.NullString
+		if err := rows.Scan(&date, &amount, &note); err != nil {
+			return nil, err
+		}
+		counts[dedupeKey(date, amount, note.String)]++
+	}
+	return counts, rows.Err()
+}
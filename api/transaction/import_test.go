@@ -0,0 +1,164 @@
+package transaction
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const importCSV = "Posted,Value,Description\n" +
+	"18/05/2024,-120.50,Lunch\n" +
+	"18/05/2024,-120.50,Lunch\n" +
+	"19/05/2024,30000,Salary\n"
+
+const importMapping = `{"date":"Posted","amount":"Value","note":"Description","date_layout":"02/01/2006"}`
+
+func setupImportTest(query, filename, content string, fields map[string]string) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	part, _ := w.CreateFormFile("file", filename)
+	_, _ = part.Write([]byte(content))
+	_ = w.Close()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/spenders/1/transactions/import"+query, &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("spenderId")
+	c.SetParamValues("1")
+	return c, rec
+}
+
+func expectExisting(mock sqlmock.Sqlmock) {
+	date := time.Date(2024, 5, 18, 12, 30, 0, 0, time.UTC)
+	mock.ExpectQuery(existingStatement).
+		WithArgs(1, time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 21, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "amount", "note"}).AddRow(date, "120.50", "Lunch"))
+}
+
+func TestImport(t *testing.T) {
+	t.Run("dry run previews rows and flags duplicates", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupImportTest("?dry_run=true", "may.csv", importCSV, map[string]string{"mapping": importMapping, "category": "Bank"})
+		expectExisting(mock)
+
+		err := New(db).Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"dry_run":true,"imported":2,"duplicates":1,"rows":[
+{"date":"2024-05-18T00:00:00Z","amount":120.5,"currency":"","category":"Bank","transaction_type":"expense","note":"Lunch","duplicate":true},
+{"date":"2024-05-18T00:00:00Z","amount":120.5,"currency":"","category":"Bank","transaction_type":"expense","note":"Lunch","duplicate":false},
+{"date":"2024-05-19T00:00:00Z","amount":30000,"currency":"","category":"Bank","transaction_type":"income","note":"Salary","duplicate":false}]}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("import stores new rows in one transaction", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupImportTest("", "may.csv", importCSV, map[string]string{"mapping": importMapping})
+		expectExisting(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStatement).
+			WithArgs(time.Date(2024, 5, 18, 0, 0, 0, 0, time.UTC), money.MustParse("120.50"), defaultCategory, "expense", "Lunch", "", 1, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(10, "THB"))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionCreate, audit.EntityTransaction, int64(10), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(insertStatement).
+			WithArgs(time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC), money.MustParse("30000"), defaultCategory, "income", "Salary", "", 1, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(11, "THB"))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionCreate, audit.EntityTransaction, int64(11), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(db).Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"imported":2,"duplicates":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a failed insert stores nothing", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupImportTest("", "may.csv", importCSV, map[string]string{"mapping": importMapping})
+		expectExisting(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStatement).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		err := New(db).Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("import an ofx statement", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		ofx := "<OFX><CURDEF>USD<STMTTRN><DTPOSTED>20240518<TRNAMT>-9.99<NAME>Books</STMTTRN></OFX>"
+		c, rec := setupImportTest("?dry_run=1", "may.qfx", ofx, nil)
+		mock.ExpectQuery(existingStatement).WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"date", "amount", "note"}))
+
+		err := New(db).Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"dry_run":true,"imported":1,"duplicates":0,"rows":[
+{"date":"2024-05-18T00:00:00Z","amount":9.99,"currency":"USD","category":"Uncategorized","transaction_type":"expense","note":"Books","duplicate":false}]}`, rec.Body.String())
+	})
+
+	t.Run("a request over the limit is refused before it is read", func(t *testing.T) {
+		c, rec := setupImportTest("", "may.csv", strings.Repeat("x", maxImportRequest), nil)
+
+		err := New(nil).Import(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.JSONEq(t, fmt.Sprintf(`{"message":"request must not exceed %d bytes"}`, maxImportRequest), rec.Body.String())
+	})
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		fields   map[string]string
+		want     string
+	}{
+		{"unknown format", "may.xlsx", "", nil, `{"message":"format must be csv or ofx"}`},
+		{"bad mapping", "may.csv", importCSV, map[string]string{"mapping": "date"}, `{"message":"mapping must be a JSON object"}`},
+		{"bad row", "may.csv", "date,amount\n2024-05-18,abc\n", nil, `{"message":"line 2: amount must be a decimal number"}`},
+		{"bad currency", "may.csv", "date,amount,currency\n2024-05-18,1,baht\n", nil, `{"message":"row 1: currency must be a 3-letter ISO 4217 code"}`},
+		{"no rows", "may.csv", "date,amount\n", nil, `{"message":"statement has no transactions"}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, rec := setupImportTest("", tc.filename, tc.content, tc.fields)
+
+			err := New(nil).Import(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.JSONEq(t, tc.want, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPage  = 1
+	defaultLimit = 10
+	maxLimit     = 100
+	dateLayout   = "2006-01-02"
+)
+
+const (
+	listSelect  = `SELECT ` + columns + ` FROM transaction`
+	countSelect = `SELECT COUNT(*) FROM transaction`
+	// summarySelect adds up the matching transactions in the base currency
+	// of their spenders, like the summary package, with one row per base
+	// currency so that spenders keeping their books in different currencies
+	// are never added together. Drafts are not in transaction_base and
+	// transactions without a known exchange rate have no base_amount there,
+	// so both are listed but left out of the totals. The format verbs are the
+	// placeholders of the income and expense types, the %s the matching
+	// transactions.
+	summarySelect = `SELECT b.base_currency,
+	COALESCE(SUM(b.base_amount) FILTER (WHERE b.transaction_type = $%d), 0),
+	COALESCE(SUM(b.base_amount) FILTER (WHERE b.transaction_type = $%d), 0)
+FROM (SELECT id FROM transaction%s) t JOIN transaction_base b ON b.id = t.id
+GROUP BY b.base_currency ORDER BY b.base_currency`
+)
+
+type summaryResponse struct {
+	BaseCurrency   string       `json:"base_currency"`
+	TotalIncome    money.Amount `json:"total_income"`
+	TotalExpenses  money.Amount `json:"total_expenses"`
+	CurrentBalance money.Amount `json:"current_balance"`
+}
+
+type pagination struct {
+	CurrentPage int `json:"current_page"`
+	TotalPages  int `json:"total_pages"`
+	PerPage     int `json:"per_page"`
+}
+
+type listResponse struct {
+	Transactions []response        `json:"transactions"`
+	Summary      []summaryResponse `json:"summary"`
+	Pagination   pagination        `json:"pagination"`
+}
+
+// listQuery holds the paging and the SQL conditions built from the
+// GET /transactions query string.
+type listQuery struct {
+	Page       int
+	Limit      int
+	conditions []string
+	args       []any
+}
+
+func (q *listQuery) where(condition string, arg any) {
+	q.args = append(q.args, arg)
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, len(q.args)))
+}
+
+func (q listQuery) whereClause() string {
+	return " WHERE " + strings.Join(append([]string{visible}, q.conditions...), " AND ")
+}
+
+func (q listQuery) countStatement() (string, []any) {
+	return countSelect + q.whereClause(), q.args
+}
+
+func (q listQuery) summaryStatement() (string, []any) {
+	n := len(q.args)
+	stmt := fmt.Sprintf(summarySelect, n+1, n+2, q.whereClause())
+	args := append(append([]any{}, q.args...), txtype.Income, txtype.Expense)
+	return stmt, args
+}
+
+func (q listQuery) listStatement() (string, []any) {
+	n := len(q.args)
+	stmt := fmt.Sprintf("%s%s ORDER BY date DESC, id DESC LIMIT $%d OFFSET $%d", listSelect, q.whereClause(), n+1, n+2)
+	args := append(append([]any{}, q.args...), q.Limit, (q.Page-1)*q.Limit)
+	return stmt, args
+}
+
+func parsePositiveInt(c echo.Context, name string, fallback int) (int, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return v, nil
+}
+
+func parseDate(c echo.Context, name string) (time.Time, bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	d, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s must be in YYYY-MM-DD format", name)
+	}
+	return d, true, nil
+}
+
+func parseAmount(c echo.Context, name string) (money.Amount, bool, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return 0, false, nil
+	}
+	v, err := money.Parse(raw)
+	if errors.Is(err, money.ErrPrecision) {
+		return 0, false, fmt.Errorf("%s must not have more than 2 decimal places", name)
+	}
+	if err != nil || v < 0 {
+		return 0, false, fmt.Errorf("%s must be a non-negative number", name)
+	}
+	return v, true, nil
+}
+
+// parseListQuery reads the query string of the caller. Admins may list the
+// transactions of any spender, or of all of them; everyone else only ever
+// lists their own, whatever spender_id says.
+func parseListQuery(c echo.Context, caller auth.Spender) (listQuery, error) {
+	var q listQuery
+	var err error
+	if q.Page, err = parsePositiveInt(c, "page", defaultPage); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parsePositiveInt(c, "limit", defaultLimit); err != nil {
+		return q, err
+	}
+	if q.Limit > maxLimit {
+		return q, fmt.Errorf("limit must not exceed %d", maxLimit)
+	}
+
+	if !caller.IsAdmin() {
+		q.where("spender_id = $%d", caller.ID)
+	} else if raw := c.QueryParam("spender_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return q, errors.New("spender_id must be an integer")
+		}
+		q.where("spender_id = $%d", id)
+	}
+
+	day, ok, err := parseDate(c, "date")
+	if err != nil {
+		return q, err
+	}
+	if ok {
+		q.where("date >= $%d", day)
+		q.where("date < $%d", day.AddDate(0, 0, 1))
+	}
+	from, hasFrom, err := parseDate(c, "date_from")
+	if err != nil {
+		return q, err
+	}
+	to, hasTo, err := parseDate(c, "date_to")
+	if err != nil {
+		return q, err
+	}
+	if hasFrom && hasTo && to.Before(from) {
+		return q, errors.New("date_to must not be before date_from")
+	}
+	if hasFrom {
+		q.where("date >= $%d", from)
+	}
+	if hasTo {
+		q.where("date < $%d", to.AddDate(0, 0, 1))
+	}
+
+	amount, ok, err := parseAmount(c, "amount")
+	if err != nil {
+		return q, err
+	}
+	if ok {
+		q.where("amount = $%d", amount)
+	}
+	minAmount, hasMin, err := parseAmount(c, "amount_min")
+	if err != nil {
+		return q, err
+	}
+	maxAmount, hasMax, err := parseAmount(c, "amount_max")
+	if err != nil {
+		return q, err
+	}
+	if hasMin && hasMax && maxAmount < minAmount {
+		return q, errors.New("amount_max must not be less than amount_min")
+	}
+	if hasMin {
+		q.where("amount >= $%d", minAmount)
+	}
+	if hasMax {
+		q.where("amount <= $%d", maxAmount)
+	}
+
+	if category := c.QueryParam("category"); category != "" {
+		q.where("category = $%d", category)
+	}
+	if raw := c.QueryParam("transaction_type"); raw != "" {
+		tranType, err := txtype.Parse(raw)
+		if err != nil {
+			return q, err
+		}
+		q.where("transaction_type = $%d", tranType)
+	}
+	return q, nil
+}
+
+// GetAll lists transactions page by page, filtered by spender, date, amount,
+// category and type, together with the income and expense totals of
+// everything that matches the filters, each transaction converted into the
+// base currency of its spender. The totals come in one block per base
+// currency. Admins see every spender; other callers see only their own
+// transactions.
+func (h handler) GetAll(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	caller, ok := auth.Current(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, transactionError{Message: "unauthorized"})
+	}
+	q, err := parseListQuery(c, caller)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+	}
+
+	var count int
+	stmt, args := q.countStatement()
+	if err := h.db.QueryRowContext(ctx, stmt, args...).Scan(&count); err != nil {
+		logger.Error("query count error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query count error"})
+	}
+	sums, err := h.summaries(ctx, q)
+	if err != nil {
+		logger.Error("query summary error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query summary error"})
+	}
+
+	stmt, args = q.listStatement()
+	rows, err := h.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+	}
+	defer rows.Close()
+
+	res := listResponse{
+		Transactions: []response{},
+		Summary:      sums,
+		Pagination: pagination{
+			CurrentPage: q.Page,
+			TotalPages:  (count + q.Limit - 1) / q.Limit,
+			PerPage:     q.Limit,
+		},
+	}
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, transactionError{Message: "scan error"})
+		}
+		res.Transactions = append(res.Transactions, t)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h handler) summaries(ctx context.Context, q listQuery) ([]summaryResponse, error) {
+	stmt, args := q.summaryStatement()
+	rows, err := h.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sums := []summaryResponse{}
+	for rows.Next() {
+		var sum summaryResponse
+		if err := rows.Scan(&sum.BaseCurrency, &sum.TotalIncome, &sum.TotalExpenses); err != nil {
+			return nil, err
+		}
+		sum.CurrentBalance = sum.TotalIncome - sum.TotalExpenses
+		sums = append(sums, sum)
+	}
+	return sums, rows.Err()
+}
@@ -0,0 +1,228 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	admin       = auth.Spender{ID: 9, Role: auth.RoleAdmin}
+	summaryCols = []string{"base_currency", "income", "expense"}
+)
+
+func newListContext(query string, caller auth.Spender) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/transactions"+query, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	auth.Set(c, caller)
+	return c, rec
+}
+
+func TestParseListQuery(t *testing.T) {
+	day := time.Date(2024, time.May, 18, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		query     string
+		wantWhere string
+		wantArgs  []any
+		caller    auth.Spender
+		wantPage  int
+		wantLimit int
+	}{
+		{"defaults", "", " WHERE " + visible, nil, admin, 1, 10},
+		{"paging", "?page=3&limit=20", " WHERE " + visible, nil, admin, 3, 20},
+		{"single date", "?date=2024-05-18", " WHERE " + visible + " AND date >= $1 AND date < $2", []any{day, day.AddDate(0, 0, 1)}, admin, 1, 10},
+		{"date range", "?date_from=2024-05-18&date_to=2024-05-18", " WHERE " + visible + " AND date >= $1 AND date < $2", []any{day, day.AddDate(0, 0, 1)}, admin, 1, 10},
+		{"amount range", "?amount_min=10&amount_max=20.5", " WHERE " + visible + " AND amount >= $1 AND amount <= $2", []any{money.MustParse("10"), money.MustParse("20.5")}, admin, 1, 10},
+		{"category and type", "?category=Food&transaction_type=EXPENSE", " WHERE " + visible + " AND category = $1 AND transaction_type = $2", []any{"Food", txtype.Expense}, admin, 1, 10},
+		{"spender and amount", "?spender_id=2&amount=1000", " WHERE " + visible + " AND spender_id = $1 AND amount = $2", []any{2, money.MustParse("1000")}, admin, 1, 10},
+		{"spender sees only their own", "?spender_id=2", " WHERE " + visible + " AND spender_id = $1", []any{int64(1)}, auth.Spender{ID: 1, Role: auth.RoleSpender}, 1, 10},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newListContext(tc.query, tc.caller)
+
+			q, err := parseListQuery(c, tc.caller)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantWhere, q.whereClause())
+			assert.Equal(t, tc.wantArgs, q.args)
+			assert.Equal(t, tc.wantPage, q.Page)
+			assert.Equal(t, tc.wantLimit, q.Limit)
+		})
+	}
+}
+
+func TestParseListQueryInvalid(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"?page=0", "page must be a positive integer"},
+		{"?limit=abc", "limit must be a positive integer"},
+		{"?limit=101", "limit must not exceed 100"},
+		{"?date=18-05-2024", "date must be in YYYY-MM-DD format"},
+		{"?date_from=2024-05-18&date_to=2024-05-01", "date_to must not be before date_from"},
+		{"?amount=-1", "amount must be a non-negative number"},
+		{"?amount_min=20&amount_max=10", "amount_max must not be less than amount_min"},
+		{"?amount=10.001", "amount must not have more than 2 decimal places"},
+		{"?transaction_type=transfer", "invalid transaction type"},
+		{"?spender_id=abc", "spender_id must be an integer"},
+	}
+
+	for _, tc := range cases {
+		c, _ := newListContext(tc.query, admin)
+
+		_, err := parseListQuery(c, admin)
+
+		assert.EqualError(t, err, tc.want, tc.query)
+	}
+}
+
+func TestGetAllTransaction(t *testing.T) {
+	txCols := []string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type", "currency", "version", "draft"}
+
+	t.Run("get paginated transactions successfully", func(t *testing.T) {
+		c, rec := newListContext("?page=2&limit=1&category=Food", admin)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(countSelect + " WHERE " + visible + " AND category = $1").WithArgs("Food").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(fmt.Sprintf(summarySelect, 2, 3, " WHERE "+visible+" AND category = $1")).WithArgs("Food", txtype.Income, txtype.Expense).
+			WillReturnRows(sqlmock.NewRows(summaryCols).AddRow("THB", 2000, 1000))
+		date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		rows := sqlmock.NewRows(txCols).AddRow(1, date, 1000, "Food", "Lunch", "", 1, "expense", "THB", 1, false)
+		mock.ExpectQuery(listSelect+" WHERE "+visible+" AND category = $1 ORDER BY date DESC, id DESC LIMIT $2 OFFSET $3").
+			WithArgs("Food", 1, 1).WillReturnRows(rows)
+
+		h := New(db)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":1000,"category":"Food","note":"Lunch","image_url":"","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false}],
+			"summary": [{"base_currency": "THB", "total_income": 2000, "total_expenses": 1000, "current_balance": 1000}],
+			"pagination": {"current_page": 2, "total_pages": 2, "per_page": 1}
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("totals of spenders with different base currencies are kept apart", func(t *testing.T) {
+		c, rec := newListContext("?limit=2", admin)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(countSelect + " WHERE " + visible).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(fmt.Sprintf(summarySelect, 1, 2, " WHERE "+visible)).WithArgs(txtype.Income, txtype.Expense).
+			WillReturnRows(sqlmock.NewRows(summaryCols).AddRow("THB", 0, 1000).AddRow("USD", 50, 0))
+		date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		rows := sqlmock.NewRows(txCols).
+			AddRow(2, date, 50, "Salary", "", "", 2, "income", "USD", 1, false).
+			AddRow(1, date, 1000, "Food", "Lunch", "", 1, "expense", "THB", 1, false)
+		mock.ExpectQuery(listSelect+" WHERE "+visible+" ORDER BY date DESC, id DESC LIMIT $1 OFFSET $2").
+			WithArgs(2, 0).WillReturnRows(rows)
+
+		err := New(db).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got listResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Len(t, got.Transactions, 2)
+		assert.Equal(t, []summaryResponse{
+			{BaseCurrency: "THB", TotalExpenses: money.MustParse("1000"), CurrentBalance: money.MustParse("-1000")},
+			{BaseCurrency: "USD", TotalIncome: money.MustParse("50"), CurrentBalance: money.MustParse("50")},
+		}, got.Summary)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("a spender lists only their own transactions", func(t *testing.T) {
+		c, rec := newListContext("?spender_id=2", auth.Spender{ID: 1, Role: auth.RoleSpender})
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(countSelect + " WHERE " + visible + " AND spender_id = $1").WithArgs(int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(fmt.Sprintf(summarySelect, 2, 3, " WHERE "+visible+" AND spender_id = $1")).WithArgs(int64(1), txtype.Income, txtype.Expense).
+			WillReturnRows(sqlmock.NewRows(summaryCols))
+		mock.ExpectQuery(listSelect+" WHERE "+visible+" AND spender_id = $1 ORDER BY date DESC, id DESC LIMIT $2 OFFSET $3").
+			WithArgs(int64(1), 10, 0).WillReturnRows(sqlmock.NewRows(txCols))
+
+		err := New(db).GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{
+			"transactions": [],
+			"summary": [],
+			"pagination": {"current_page": 1, "total_pages": 0, "per_page": 10}
+		}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get transactions failed when query is invalid", func(t *testing.T) {
+		c, rec := newListContext("?limit=1000", admin)
+
+		h := New(nil)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message": "limit must not exceed 100"}`, rec.Body.String())
+	})
+
+	t.Run("get transactions failed on summary query", func(t *testing.T) {
+		c, rec := newListContext("", admin)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(countSelect + " WHERE " + visible).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(fmt.Sprintf(summarySelect, 1, 2, " WHERE "+visible)).WillReturnError(assert.AnError)
+
+		h := New(db)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get transactions failed on list query", func(t *testing.T) {
+		c, rec := newListContext("", admin)
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(countSelect + " WHERE " + visible).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(fmt.Sprintf(summarySelect, 1, 2, " WHERE "+visible)).
+			WillReturnRows(sqlmock.NewRows(summaryCols).AddRow("THB", 0, 10))
+		mock.ExpectQuery(listSelect + " WHERE " + visible + " ORDER BY date DESC, id DESC LIMIT $1 OFFSET $2").WillReturnError(assert.AnError)
+
+		h := New(db)
+		err := h.GetAll(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,123 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+
+	mimeMergePatch = "application/merge-patch+json"
+)
+
+var errVersionConflict = errors.New("transaction has been modified, fetch it again and retry")
+
+// etag is the strong entity tag of a transaction version.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatch reports whether the If-Match header of the request, if any, names
+// the given version. Weak tags never match, as RFC 9110 requires a strong
+// comparison for If-Match.
+func ifMatch(c echo.Context, version int) bool {
+	header := c.Request().Header.Get(headerIfMatch)
+	if header == "" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPatch applies a JSON merge patch (RFC 7396) to the current state of a
+// transaction and validates the result like a full update. note and image_url
+// are cleared by null; the other fields are required and cannot be.
+func applyPatch(t response, patch map[string]json.RawMessage) (request, error) {
+	req := request{
+		Date:            t.Date,
+		Amount:          t.Amount,
+		Currency:        t.Currency,
+		Category:        t.Category,
+		TransactionType: t.TransactionType,
+		Note:            t.Note,
+		ImageUrl:        t.ImageUrl,
+		SpenderId:       t.SpenderId,
+	}
+
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		raw := patch[field]
+		var dst any
+		switch field {
+		case "date":
+			dst = &req.Date
+		case "amount":
+			dst = &req.Amount
+		case "currency":
+			dst = &req.Currency
+		case "category":
+			dst = &req.Category
+		case "transaction_type":
+			dst = &req.TransactionType
+		case "note":
+			dst = &req.Note
+		case "image_url":
+			dst = &req.ImageUrl
+		default:
+			return req, fmt.Errorf("%s cannot be patched", field)
+		}
+
+		if string(raw) == "null" {
+			if field != "note" && field != "image_url" {
+				return req, fmt.Errorf("%s cannot be null", field)
+			}
+			raw = []byte(`""`)
+		}
+		if err := json.Unmarshal(raw, dst); err != nil {
+			if cause := money.Cause(err); cause != nil {
+				return req, cause
+			}
+			return req, fmt.Errorf("%s is invalid", field)
+		}
+	}
+	return validateTransaction(req)
+}
+
+// Patch changes the fields present in a JSON merge patch body and returns
+// the updated transaction.
+func (h *handler) Patch(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != mimeMergePatch && mediaType != echo.MIMEApplicationJSON {
+		return c.JSON(http.StatusUnsupportedMediaType, transactionError{Message: "content type must be " + mimeMergePatch})
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil || patch == nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: "body must be a JSON object"})
+	}
+
+	return h.save(c, func(before response) (request, error) {
+		return applyPatch(before, patch)
+	})
+}
@@ -0,0 +1,114 @@
+package transaction
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPatchTest(body, contentType string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/spenders/1/transactions/1", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("spenderId", "transId")
+	c.SetParamValues("1", "1")
+	return c, rec
+}
+
+func TestPatch(t *testing.T) {
+	t.Run("patch changes only the given fields", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupPatchTest(`{"amount": 70, "note": null}`, mimeMergePatch)
+		c.Request().Header.Set("If-Match", `"1"`)
+
+		before := mockTransactionRows(66.6)
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WithArgs("1", "1").WillReturnRows(before)
+		date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		mock.ExpectQuery(updateStatment).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Food", "", "", "THB", "expense", "1", "1").
+			WillReturnRows(sqlmock.NewRows(transactionColumns).AddRow(1, date, 70, "Food", "", "", 1, "expense", "THB", 2, false))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionUpdate, audit.EntityTransaction, int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(db).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		assert.JSONEq(t, `{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":70,"category":"Food","note":"","image_url":"","spender_id":1,"transaction_type":"expense","currency":"THB","version":2,"draft":false}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("patch with a stale If-Match fails", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupPatchTest(`{"amount": 70}`, mimeMergePatch)
+		c.Request().Header.Set("If-Match", `"3", "4"`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
+		mock.ExpectRollback()
+
+		err := New(db).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("patch rejects bad fields", func(t *testing.T) {
+		tests := []struct {
+			body string
+			want string
+		}{
+			{`{"category": null}`, "category cannot be null"},
+			{`{"spender_id": 2}`, "spender_id cannot be patched"},
+			{`{"date": "yesterday"}`, "date is invalid"},
+			{`{"category": ""}`, "category is required"},
+		}
+		for _, tc := range tests {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			c, rec := setupPatchTest(tc.body, echo.MIMEApplicationJSON)
+			mock.ExpectBegin()
+			mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
+			mock.ExpectRollback()
+
+			err := New(db).Patch(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tc.body)
+			assert.JSONEq(t, `{"message":"`+tc.want+`"}`, rec.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+			db.Close()
+		}
+	})
+
+	t.Run("patch rejects other content types", func(t *testing.T) {
+		c, rec := setupPatchTest(`amount=70`, echo.MIMEApplicationForm)
+
+		err := New(nil).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("patch body must be an object", func(t *testing.T) {
+		c, rec := setupPatchTest(`[1]`, mimeMergePatch)
+
+		err := New(nil).Patch(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -4,6 +4,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/currency"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/dberr"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
 	"github.com/kkgo-software-engineering/workshop/mlog"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -11,11 +17,22 @@ import (
 	"time"
 )
 
+// visible hides trashed transactions and those of soft-deleted spenders from
+// every listing.
+const visible = `deleted_at IS NULL AND spender_id IN (SELECT id FROM spender WHERE deleted_at IS NULL)`
+
+// columns are scanned by scanTransaction.
+const columns = `id, date, amount, category, note, image_url, spender_id, transaction_type, currency, version, draft`
+
 const (
-	insertStatement = `INSERT INTO transaction (date, amount, category, transaction_type, note, image_url, spender_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
-	updateStatment = `UPDATE transaction SET date = $1 , amount = $2, category = $3 , note = $4, image_url = $5 WHERE id = $6 AND spender_id = $7;`
-	deleteStatment = `DELETE FROM transaction WHERE id = $1 AND spender_id = $2;`
+	insertStatement = `INSERT INTO transaction (date, amount, category, transaction_type, note, image_url, spender_id, currency)
+VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8, ''), (SELECT base_currency FROM spender WHERE id = $7), 'THB')) RETURNING id, currency;`
+	findStatement          = `SELECT ` + columns + ` FROM transaction WHERE id = $1 AND spender_id = $2 AND ` + visible
+	findForUpdateStatement = `SELECT ` + columns + ` FROM transaction WHERE id = $1 AND spender_id = $2 AND deleted_at IS NULL FOR UPDATE;`
+	updateStatment         = `UPDATE transaction SET date = $1 , amount = $2, category = $3 , note = $4, image_url = $5, currency = COALESCE(NULLIF($6, ''), currency), transaction_type = $7, version = version + 1, draft = FALSE
+WHERE id = $8 AND spender_id = $9 AND deleted_at IS NULL RETURNING ` + columns
+	deleteStatment     = `UPDATE transaction SET deleted_at = now() WHERE id = $1 AND spender_id = $2 AND deleted_at IS NULL;`
+	bySpenderStatement = `SELECT ` + columns + ` FROM transaction WHERE transaction_type = $1 AND spender_id = $2 AND ` + visible
 )
 
 type transactionError struct {
@@ -23,24 +40,36 @@ type transactionError struct {
 }
 
 type request struct {
-	Date            time.Time `json:"date"`
-	Amount          float64   `json:"amount"`
-	Category        string    `json:"category"`
-	TransactionType string    `json:"transaction_type"`
-	Note            string    `json:"note"`
-	ImageUrl        string    `json:"image_url"`
-	SpenderId       int       `json:"spender_id"`
+	Date            time.Time              `json:"date"`
+	Amount          money.Amount           `json:"amount"`
+	Currency        string                 `json:"currency"`
+	Category        string                 `json:"category"`
+	TransactionType txtype.TransactionType `json:"transaction_type"`
+	Note            string                 `json:"note"`
+	ImageUrl        string                 `json:"image_url"`
+	SpenderId       int                    `json:"spender_id"`
 }
 
 type response struct {
-	Id              int       `json:"id"`
-	Date            time.Time `json:"date"`
-	Amount          float64   `json:"amount"`
-	Category        string    `json:"category"`
-	TransactionType string    `json:"transaction_type"`
-	Note            string    `json:"note"`
-	ImageUrl        string    `json:"image_url"`
-	SpenderId       int       `json:"spender_id"`
+	Id              int                    `json:"id"`
+	Date            time.Time              `json:"date"`
+	Amount          money.Amount           `json:"amount"`
+	Currency        string                 `json:"currency"`
+	Category        string                 `json:"category"`
+	TransactionType txtype.TransactionType `json:"transaction_type"`
+	Note            string                 `json:"note"`
+	ImageUrl        string                 `json:"image_url"`
+	SpenderId       int                    `json:"spender_id"`
+	Version         int                    `json:"version"`
+	// Draft is set on transactions made from a slip until the spender saves
+	// them; summaries, budgets, exports and the totals of the transaction
+	// list leave drafts out.
+	Draft bool `json:"draft"`
+}
+
+type pageResponse struct {
+	Transactions []response `json:"transactions"`
+	NextCursor   string     `json:"next_cursor"`
 }
 
 type handler struct {
@@ -58,106 +87,268 @@ func (h *handler) Create(c echo.Context) error {
 	err := c.Bind(&req)
 	if err != nil {
 		logger.Error("error", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, transactionError{Message: "invalid request body"})
+		return c.JSON(http.StatusBadRequest, transactionError{Message: bindMessage(err)})
 	}
-	if err = validateTransaction(req); err != nil {
+	if req, err = validateTransaction(req); err != nil {
 		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
 	}
-	var lastInsertId int
-	err = h.db.QueryRowContext(ctx, insertStatement, req.Date, req.Amount, req.Category,
-		req.TransactionType, req.Note, req.ImageUrl, req.SpenderId).Scan(&lastInsertId)
+	if !auth.CanAccess(c, int64(req.SpenderId)) {
+		return auth.Forbidden(c, auth.ErrForbidden.Error())
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("begin transaction error:", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	defer tx.Rollback()
+
+	t := response{Date: req.Date, Amount: req.Amount, Category: req.Category, TransactionType: req.TransactionType,
+		Note: req.Note, ImageUrl: req.ImageUrl, SpenderId: req.SpenderId}
+	err = tx.QueryRowContext(ctx, insertStatement, req.Date, req.Amount, req.Category,
+		req.TransactionType, req.Note, req.ImageUrl, req.SpenderId, req.Currency).Scan(&t.Id, &t.Currency)
+	if dberr.IsForeignKey(err) {
+		return c.JSON(http.StatusNotFound, transactionError{Message: "spender not found"})
+	}
+	if dberr.IsInvalid(err) {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: "invalid transaction"})
+	}
 	if err != nil {
 		logger.Error("insert transaction into transaction table error:", zap.Error(err))
 		return c.NoContent(http.StatusInternalServerError)
 	}
+	if err := h.commit(c, tx, audit.ActionCreate, t.Id, nil, t); err != nil {
+		logger.Error("commit transaction error:", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
 	return c.NoContent(http.StatusCreated)
 }
 
-func validateTransaction(req request) error {
-	if req.Amount < 0.0 {
-		return errors.New("amount is lower than 0.0")
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTransaction(row scanner) (response, error) {
+	var t response
+	err := row.Scan(&t.Id, &t.Date, &t.Amount, &t.Category, &t.Note, &t.ImageUrl, &t.SpenderId, &t.TransactionType, &t.Currency, &t.Version, &t.Draft)
+	return t, err
+}
+
+// commit records the change in the audit log and commits it together with
+// the change itself.
+func (h *handler) commit(c echo.Context, tx *sql.Tx, action string, id int, before, after any) error {
+	err := audit.Record(c, tx, audit.Entry{
+		Action:   action,
+		Entity:   audit.EntityTransaction,
+		EntityID: int64(id),
+		Before:   before,
+		After:    after,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// bindMessage tells the client why an amount was rejected and hides any other
+// decoding detail.
+func bindMessage(err error) string {
+	if cause := money.Cause(err); cause != nil {
+		return cause.Error()
+	}
+	return "invalid request body"
+}
+
+// validateTransaction checks req and returns it with the currency code
+// normalized. An empty currency means the spender's base currency.
+func validateTransaction(req request) (request, error) {
+	if req.Amount < 0 {
+		return req, errors.New("amount is lower than 0.0")
 	} else if req.Category == "" {
-		return errors.New("category is required")
+		return req, errors.New("category is required")
+	} else if !req.TransactionType.Valid() {
+		return req, txtype.ErrInvalid
+	}
+	if req.Currency != "" {
+		code, err := currency.Normalize(req.Currency)
+		if err != nil {
+			return req, err
+		}
+		req.Currency = code
 	}
-	return nil
+	return req, nil
 }
 
+// GetAllBySpender returns one page of a spender's transactions, newest first.
+// The next page is requested with the returned next_cursor, which is empty
+// once the last page has been reached.
 func (h handler) GetAllBySpender(c echo.Context) error {
 	logger := mlog.L(c)
 	ctx := c.Request().Context()
 	spenderId := c.Param("spenderId")
-	tranType := c.QueryParam("transaction_type")
-	if tranType != "EXPENSE" && tranType != "INCOME" {
-		return c.JSON(http.StatusBadRequest, transactionError{Message: "invalid transaction type"})
+	tranType, err := txtype.Parse(c.QueryParam("transaction_type"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+	}
+	limit, err := parsePositiveInt(c, "limit", defaultLimit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	stmt := bySpenderStatement
+	args := []any{tranType, spenderId}
+	if raw := c.QueryParam("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+		}
+		stmt += ` AND (date, id) < ($3, $4)`
+		args = append(args, cur.Date, cur.ID)
 	}
-	rows, err := h.db.QueryContext(ctx, `SELECT id, date, amount, category, note, image_url, spender_id, transaction_type FROM transaction where transaction_type = $1 and spender_id = $2`, tranType, spenderId)
+	stmt += fmt.Sprintf(` ORDER BY date DESC, id DESC LIMIT $%d`, len(args)+1)
+	// one extra row tells whether there is a next page
+	args = append(args, limit+1)
+
+	rows, err := h.db.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		logger.Error("query error", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 	defer rows.Close()
 
-	var res []response
+	res := pageResponse{Transactions: []response{}}
 	for rows.Next() {
-		var t response
-		err := rows.Scan(&t.Id, &t.Date, &t.Amount, &t.Category, &t.Note, &t.ImageUrl, &t.SpenderId, &t.TransactionType)
+		t, err := scanTransaction(rows)
 		if err != nil {
 			logger.Error("scan error", zap.Error(err))
 			return c.JSON(http.StatusInternalServerError, err.Error())
 		}
-		res = append(res, t)
+		res.Transactions = append(res.Transactions, t)
+	}
+	if len(res.Transactions) > limit {
+		res.Transactions = res.Transactions[:limit]
+		last := res.Transactions[limit-1]
+		res.NextCursor = cursor{Date: last.Date, ID: last.Id}.encode()
 	}
 	return c.JSON(http.StatusOK, res)
 }
 
-func (h *handler) Update(c echo.Context) error {
+// GetByID returns one transaction of the spender with its ETag.
+func (h handler) GetByID(c echo.Context) error {
 	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	t, err := scanTransaction(h.db.QueryRowContext(ctx, findStatement, c.Param("transId"), c.Param("spenderId")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, transactionError{Message: "transaction not found"})
+	}
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+	}
+
+	c.Response().Header().Set(headerETag, etag(t.Version))
+	return c.JSON(http.StatusOK, t)
+}
+
+// Update replaces every field of the transaction and returns it. Saving a
+// draft confirms it.
+func (h *handler) Update(c echo.Context) error {
 	var req request
-	spenderId := c.Param("spenderId")
-	transId := c.Param("transId")
 	err := c.Bind(&req)
 	if err != nil {
-		logger.Error("error", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, transactionError{Message: "invalid request body"})
+		mlog.L(c).Error("error", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, transactionError{Message: bindMessage(err)})
 	}
-	if err = validateTransaction(req); err != nil {
+	if req, err = validateTransaction(req); err != nil {
 		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
 	}
-	result, err := h.db.Exec(updateStatment, req.Date, req.Amount, req.Category, req.Note, req.ImageUrl, transId, spenderId)
+
+	return h.save(c, func(response) (request, error) { return req, nil })
+}
+
+// save locks the transaction of the request, stores the request build makes
+// from its current state and returns the result with its new ETag. build
+// returns a validated request. A stale If-Match header is refused with 412
+// before build runs.
+func (h *handler) save(c echo.Context, build func(before response) (request, error)) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+	spenderId := c.Param("spenderId")
+	transId := c.Param("transId")
+
+	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
 		logger.Error("update transaction", zap.Error(err))
 		return c.NoContent(http.StatusInternalServerError)
 	}
-	rowAff, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	before, err := scanTransaction(tx.QueryRowContext(ctx, findForUpdateStatement, transId, spenderId))
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Error(fmt.Sprintf("Can't update transaction by id = %s and spender_id =%s", transId, spenderId))
+		return c.NoContent(http.StatusBadRequest)
+	}
 	if err != nil {
-		logger.Error("update transaction:", zap.Error(err))
+		logger.Error("update transaction", zap.Error(err))
 		return c.NoContent(http.StatusInternalServerError)
 	}
-	if rowAff == 0 {
-		logger.Error(fmt.Sprintf("Can't update transaction by id = %s and spender_id =%s", transId, spenderId))
-		return c.NoContent(http.StatusBadRequest)
+	if !ifMatch(c, before.Version) {
+		return c.JSON(http.StatusPreconditionFailed, transactionError{Message: errVersionConflict.Error()})
+	}
+	req, err := build(before)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, transactionError{Message: err.Error()})
+	}
+
+	after, err := scanTransaction(tx.QueryRowContext(ctx, updateStatment, req.Date, req.Amount, req.Category, req.Note, req.ImageUrl, req.Currency, req.TransactionType, transId, spenderId))
+	if err != nil {
+		logger.Error("update transaction", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
 	}
-	return c.JSON(http.StatusOK, "Update success")
+	if err := h.commit(c, tx, audit.ActionUpdate, after.Id, before, after); err != nil {
+		logger.Error("update transaction:", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	c.Response().Header().Set(headerETag, etag(after.Version))
+	return c.JSON(http.StatusOK, after)
 }
 
 func (h *handler) Delete(c echo.Context) error {
 	logger := mlog.L(c)
+	ctx := c.Request().Context()
 	spenderId := c.Param("spenderId")
 	transId := c.Param("transId")
 
-	result, err := h.db.Exec(deleteStatment, transId, spenderId)
+	tx, err := h.db.BeginTx(ctx, nil)
 	if err != nil {
 		logger.Error("delete transaction", zap.Error(err))
 		return c.NoContent(http.StatusInternalServerError)
 	}
-	rowAff, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	before, err := scanTransaction(tx.QueryRowContext(ctx, findForUpdateStatement, transId, spenderId))
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Error(fmt.Sprintf("Can't delete transaction by id = %s and spender_id =%s", transId, spenderId))
+		return c.NoContent(http.StatusBadRequest)
+	}
 	if err != nil {
-		logger.Error("delete transaction:", zap.Error(err))
+		logger.Error("delete transaction", zap.Error(err))
 		return c.NoContent(http.StatusInternalServerError)
 	}
-	if rowAff == 0 {
-		logger.Error(fmt.Sprintf("Can't delete transaction by id = %s and spender_id =%s", transId, spenderId))
-		return c.NoContent(http.StatusBadRequest)
+	if !ifMatch(c, before.Version) {
+		return c.JSON(http.StatusPreconditionFailed, transactionError{Message: errVersionConflict.Error()})
+	}
+	if _, err := tx.ExecContext(ctx, deleteStatment, transId, spenderId); err != nil {
+		logger.Error("delete transaction", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if err := h.commit(c, tx, audit.ActionDelete, before.Id, before, nil); err != nil {
+		logger.Error("delete transaction:", zap.Error(err))
+		return c.NoContent(http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, "Delete success")
 }
@@ -6,6 +6,8 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
 	"github.com/KKGo-Software-engineering/workshop-summer/migration"
 	"github.com/labstack/echo/v4"
@@ -21,14 +23,22 @@ import (
 func TestCreateTransactionIT(t *testing.T) {
 	t.Run("create transaction successfully", func(t *testing.T) {
 		sql := getTestDatabaseFromConfig(t)
+		spender := seedSpender(t, sql, "create-trans@jot.ok", auth.RoleSpender)
 
 		h := New(sql)
 		e := echo.New()
 		defer e.Close()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				auth.Set(c, spender)
+				return next(c)
+			}
+		})
 
 		e.POST("/transactions", h.Create)
 
 		payload := mockTransactionRequest()
+		payload.SpenderId = int(spender.ID)
 		body, err := json.Marshal(payload)
 		if err != nil {
 			log.Fatal(err)
@@ -46,55 +56,58 @@ func TestCreateTransactionIT(t *testing.T) {
 func TestGetTransactionIT(t *testing.T) {
 	t.Run("create get transactions successfully", func(t *testing.T) {
 		sql := getTestDatabaseFromConfig(t)
+		spender := seedSpender(t, sql, "get-trans@jot.ok", auth.RoleSpender)
 		h := New(sql)
 		e := echo.New()
 		defer e.Close()
 		date1, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
 		date2, _ := time.Parse(time.RFC3339, "2024-05-18T15:51:49.673703Z")
-		sql.Exec(insertStatement, date1, 66.6, "Food", "EXPENSE", "Note1234", "/img/transaction/1.jpg", 1)
-		sql.Exec(insertStatement, date2, 70.6, "Food", "EXPENSE", "Note555", "/img/transaction/2.jpg", 1)
+		sql.Exec(insertStatement, date1, 66.6, "Food", "expense", "Note1234", "/img/transaction/1.jpg", spender.ID, "")
+		sql.Exec(insertStatement, date2, 70.6, "Food", "expense", "Note555", "/img/transaction/2.jpg", spender.ID, "")
 		e.GET("/spenders/:spenderId/transactions", h.GetAllBySpender)
-		req := httptest.NewRequest(http.MethodGet, "/spenders/1/transactions?transaction_type=EXPENSE", nil)
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/spenders/%d/transactions?transaction_type=EXPENSE", spender.ID), nil)
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		e.ServeHTTP(rec, req)
 
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		wantJsonStr := `[{
-    "id": 1,
-    "date": "2024-05-18T11:51:49.673703Z",
-    "amount": 66.6,
-    "category": "Food",
-    "transaction_type": "EXPENSE",
-    "note": "Note1234",
-    "image_url": "/img/transaction/1.jpg",
-    "spender_id": 1
-  },
-  {
+		wantJsonStr := fmt.Sprintf(`[{
     "id": 2,
     "date": "2024-05-18T15:51:49.673703Z",
     "amount": 70.6,
     "category": "Food",
-    "transaction_type": "EXPENSE",
+    "transaction_type": "expense",
     "note": "Note555",
     "image_url": "/img/transaction/2.jpg",
-    "spender_id": 1
+    "spender_id": %[1]d
+  },
+  {
+    "id": 1,
+    "date": "2024-05-18T11:51:49.673703Z",
+    "amount": 66.6,
+    "category": "Food",
+    "transaction_type": "expense",
+    "note": "Note1234",
+    "image_url": "/img/transaction/1.jpg",
+    "spender_id": %[1]d
   }]
-`
+`, spender.ID)
 		var want []response
 		err := json.Unmarshal([]byte(wantJsonStr), &want)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		var got []response
-		err = json.Unmarshal(rec.Body.Bytes(), &got)
+		var page pageResponse
+		err = json.Unmarshal(rec.Body.Bytes(), &page)
 		if err != nil {
 			t.Fatal(err)
 		}
+		got := page.Transactions
 
 		assert.Equal(t, len(want), len(got))
+		assert.Empty(t, page.NextCursor)
 
 		for i := range want {
 			assert.Equal(t, want[i].Amount, got[i].Amount)
@@ -108,6 +121,158 @@ func TestGetTransactionIT(t *testing.T) {
 	})
 }
 
+func TestGetTransactionPagesIT(t *testing.T) {
+	t.Run("walk all pages with next_cursor", func(t *testing.T) {
+		sql := getTestDatabaseFromConfig(t)
+		spender := seedSpender(t, sql, "page-trans@jot.ok", auth.RoleSpender)
+		h := New(sql)
+		e := echo.New()
+		defer e.Close()
+		date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		for i := 0; i < 5; i++ {
+			// two transactions share each date to exercise the id tie-breaker
+			sql.Exec(insertStatement, date.Add(time.Duration(i/2)*time.Hour), 10+i, "Food", "expense", "", "", spender.ID, "")
+		}
+		e.GET("/spenders/:spenderId/transactions", h.GetAllBySpender)
+
+		var seen []int
+		next := ""
+		for page := 0; page < 5; page++ {
+			url := fmt.Sprintf("/spenders/%d/transactions?transaction_type=EXPENSE&limit=2&cursor=%s", spender.ID, next)
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var got pageResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			for _, tr := range got.Transactions {
+				seen = append(seen, tr.Id)
+			}
+			if got.NextCursor == "" {
+				break
+			}
+			next = got.NextCursor
+		}
+
+		assert.Len(t, seen, 5)
+		for i := 1; i < len(seen); i++ {
+			assert.NotEqual(t, seen[i-1], seen[i])
+		}
+	})
+}
+
+func TestGetAllLeavesDraftsOutOfTotalsIT(t *testing.T) {
+	db := getTestDatabaseFromConfig(t)
+	spender := seedSpender(t, db, "draft-trans@jot.ok", auth.RoleSpender)
+	date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+	db.Exec(insertStatement, date, 100, "Food", "expense", "", "", spender.ID, "")
+	var draftId int64
+	if err := db.QueryRow(insertStatement, date, 40, "Uncategorized", "expense", "slip.png", "", spender.ID, "").Scan(&draftId, new(string)); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`UPDATE transaction SET draft = TRUE WHERE id = $1`, draftId)
+
+	e := echo.New()
+	defer e.Close()
+	e.GET("/transactions", New(db).GetAll, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth.Set(c, spender)
+			return next(c)
+		}
+	})
+	req := httptest.NewRequest(http.MethodGet, "/transactions", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var got listResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// the draft is listed for the spender to confirm but not totalled
+	assert.Len(t, got.Transactions, 2)
+	if assert.Len(t, got.Summary, 1) {
+		assert.Equal(t, "100.00", got.Summary[0].TotalExpenses.String())
+	}
+}
+
+func TestSpenderOwnershipIT(t *testing.T) {
+	db := getTestDatabaseFromConfig(t)
+	h := New(db)
+	owner := seedSpender(t, db, "owner@jot.ok", auth.RoleSpender)
+	other := seedSpender(t, db, "other@jot.ok", auth.RoleSpender)
+	admin := seedSpender(t, db, "admin@jot.ok", auth.RoleAdmin)
+
+	var transId int64
+	date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+	err := db.QueryRow(insertStatement, date, 66.6, "Food", "expense", "Note1234", "/img/transaction/1.jpg", owner.ID, "").Scan(&transId, new(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newServer := func(caller auth.Spender) *echo.Echo {
+		e := echo.New()
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				auth.Set(c, caller)
+				return next(c)
+			}
+		})
+		o := auth.Owner("spenderId")
+		e.GET("/spenders/:spenderId/transactions", h.GetAllBySpender, o)
+		e.PUT("/spenders/:spenderId/transactions/:transId", h.Update, o)
+		e.DELETE("/spenders/:spenderId/transactions/:transId", h.Delete, o)
+		return e
+	}
+	body, _ := json.Marshal(mockTransactionRequest())
+	listURL := fmt.Sprintf("/spenders/%d/transactions?transaction_type=EXPENSE", owner.ID)
+	itemURL := fmt.Sprintf("/spenders/%d/transactions/%d", owner.ID, transId)
+
+	tests := []struct {
+		name   string
+		caller auth.Spender
+		method string
+		url    string
+		want   int
+	}{
+		{"other spender cannot list", other, http.MethodGet, listURL, http.StatusForbidden},
+		{"other spender cannot update", other, http.MethodPut, itemURL, http.StatusForbidden},
+		{"other spender cannot delete", other, http.MethodDelete, itemURL, http.StatusForbidden},
+		{"owner can list", owner, http.MethodGet, listURL, http.StatusOK},
+		{"owner can update", owner, http.MethodPut, itemURL, http.StatusOK},
+		{"admin can list", admin, http.MethodGet, listURL, http.StatusOK},
+		{"admin can delete", admin, http.MethodDelete, itemURL, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.url, bytes.NewBuffer(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			newServer(tc.caller).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.want, rec.Code)
+		})
+	}
+}
+
+func seedSpender(t *testing.T, db *sql.DB, email, role string) auth.Spender {
+	t.Helper()
+	sp := auth.Spender{Name: "HongJot", Email: email, Role: role}
+	err := db.QueryRow(`INSERT INTO spender (name, email, role) VALUES ($1, $2, $3) RETURNING id`, sp.Name, sp.Email, sp.Role).Scan(&sp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM spender WHERE id = $1`, sp.ID)
+	})
+	return sp
+}
+
 func getTestDatabaseFromConfig(t *testing.T) *sql.DB {
 	t.Helper()
 	cfg := config.Parse("DOCKER")
@@ -6,8 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/money"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/txtype"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -34,7 +39,7 @@ func mockTransactionRequest() request {
 	}
 	return request{
 		Date:            time.Date(2024, time.May, 18, 12, 0, 0, 0, loc),
-		Amount:          66.6,
+		Amount:          money.MustParse("66.6"),
 		Category:        "Food",
 		Note:            "Note1234",
 		ImageUrl:        "/img/transaction/1.jpg",
@@ -43,6 +48,13 @@ func mockTransactionRequest() request {
 	}
 }
 
+var transactionColumns = []string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type", "currency", "version", "draft"}
+
+func mockTransactionRows(amount float64) *sqlmock.Rows {
+	date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+	return sqlmock.NewRows(transactionColumns).AddRow(1, date, amount, "Food", "Note1234", "", 1, "expense", "THB", 1, false)
+}
+
 func setupTest(transaction request) (echo.Context, *httptest.ResponseRecorder) {
 	body, err := json.Marshal(transaction)
 	if err != nil {
@@ -54,6 +66,7 @@ func setupTest(transaction request) (echo.Context, *httptest.ResponseRecorder) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	auth.Set(c, auth.Spender{ID: int64(transaction.SpenderId), Role: auth.RoleSpender})
 	return c, rec
 }
 
@@ -78,13 +91,19 @@ func TestCreateTransaction(t *testing.T) {
 			log.Fatal(err)
 		}
 		req := mockTransactionRequest()
-		row := sqlmock.NewRows([]string{"id"}).AddRow(1)
+		row := sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "THB")
 		c, rec := setupTest(req)
+		mock.ExpectBegin()
 		mock.ExpectQuery(insertStatement).WillReturnRows(row)
+		mock.ExpectExec(audit.InsertStmt).
+			WithArgs(int64(5), audit.ActionCreate, audit.EntityTransaction, int64(1), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		h := New(db)
 		err = h.Create(c)
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Create Transaction fail request body is invalid", func(t *testing.T) {
 		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -109,7 +128,7 @@ func TestCreateTransaction(t *testing.T) {
 			log.Fatal(err)
 		}
 		req := mockTransactionRequest()
-		req.Amount = -1
+		req.Amount = money.MustParse("-1")
 		c, rec := setupTest(req)
 		h := New(db)
 		err = h.Create(c)
@@ -117,6 +136,23 @@ func TestCreateTransaction(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 		assert.JSONEq(t, `{"message":"amount is lower than 0.0"}`, rec.Body.String())
 	})
+	t.Run("Create Transaction fail amount has more than two decimals", func(t *testing.T) {
+		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		e := echo.New()
+		defer e.Close()
+		req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(`{"amount": 10.005, "category": "Food", "spender_id": 5}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"amount must not have more than 2 decimal places"}`, rec.Body.String())
+	})
 	t.Run("Create Transaction fail category is empty", func(t *testing.T) {
 		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		if err != nil {
@@ -131,6 +167,99 @@ func TestCreateTransaction(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
 		assert.JSONEq(t, `{"message":"category is required"}`, rec.Body.String())
 	})
+	t.Run("Create Transaction fail currency is invalid", func(t *testing.T) {
+		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := mockTransactionRequest()
+		req.Currency = "baht"
+		c, rec := setupTest(req)
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"currency must be a 3-letter ISO 4217 code"}`, rec.Body.String())
+	})
+	t.Run("Create Transaction with lower case currency", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := mockTransactionRequest()
+		req.Currency = "usd"
+		c, rec := setupTest(req)
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStatement).WithArgs(sqlmock.AnyArg(), req.Amount, req.Category,
+			txtype.Income, req.Note, req.ImageUrl, req.SpenderId, "USD").WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
+		mock.ExpectExec(audit.InsertStmt).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+	t.Run("Create Transaction fail transaction type is unknown", func(t *testing.T) {
+		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := mockTransactionRequest()
+		req.TransactionType = "transfer"
+		c, rec := setupTest(req)
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"invalid transaction type"}`, rec.Body.String())
+	})
+	t.Run("Create Transaction fail for another spender", func(t *testing.T) {
+		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := mockTransactionRequest()
+		c, rec := setupTest(req)
+		auth.Set(c, auth.Spender{ID: 6, Role: auth.RoleSpender})
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+	t.Run("Create Transaction for another spender as admin", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := mockTransactionRequest()
+		row := sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "THB")
+		c, rec := setupTest(req)
+		auth.Set(c, auth.Spender{ID: 6, Role: auth.RoleAdmin})
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStatement).WillReturnRows(row)
+		mock.ExpectExec(audit.InsertStmt).WithArgs(int64(6), audit.ActionCreate, audit.EntityTransaction, int64(1), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+	t.Run("Create Transaction fail spender does not exist", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req := mockTransactionRequest()
+		c, rec := setupTest(req)
+		mock.ExpectBegin()
+		mock.ExpectQuery(insertStatement).WillReturnError(&pq.Error{Code: "23503"})
+		h := New(db)
+		err = h.Create(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"message":"spender not found"}`, rec.Body.String())
+	})
 	t.Run("Create Transaction fail insert into db error", func(t *testing.T) {
 		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		if err != nil {
@@ -138,8 +267,9 @@ func TestCreateTransaction(t *testing.T) {
 		}
 		req := mockTransactionRequest()
 		c, rec := setupTest(req)
+		mock.ExpectBegin()
 		mock.ExpectQuery(insertStatement).WithArgs(anyTime{}, req.Amount, req.Category,
-			req.TransactionType, req.Note, req.ImageUrl, req.SpenderId).WillReturnError(errors.New("error"))
+			req.TransactionType, req.Note, req.ImageUrl, req.SpenderId, req.Currency).WillReturnError(errors.New("error"))
 		h := New(db)
 		err = h.Create(c)
 		assert.NoError(t, err)
@@ -148,6 +278,9 @@ func TestCreateTransaction(t *testing.T) {
 }
 
 func TestGetAllExpense(t *testing.T) {
+	columns := []string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type", "currency", "version", "draft"}
+	firstPage := bySpenderStatement + ` ORDER BY date DESC, id DESC LIMIT $3`
+
 	t.Run("get all expense successfully", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
@@ -155,23 +288,110 @@ func TestGetAllExpense(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/spenders/1/transaction?transaction_type=EXPENSE", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
+		c.SetParamNames("spenderId")
+		c.SetParamValues("1")
 
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		date1, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
-		date2, _ := time.Parse(time.RFC3339, "2024-05-18T15:51:49.673703Z")
-		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type"}).
-			AddRow(1, date1, 1000, "Lunch", "MOCK", "location/on/s3/bucket/eslip1", 1, "EXPENSE").
-			AddRow(2, date2, 2000, "Dinner", "MOCK", "location/on/s3/bucket/eslip2", 2, "EXPENSE")
-		mock.ExpectQuery(`SELECT id, date, amount, category, note, image_url, spender_id, transaction_type FROM transaction where transaction_type = $1 and spender_id = $2`).WillReturnRows(rows)
+		date1, _ := time.Parse(time.RFC3339, "2024-05-18T15:51:49.673703Z")
+		date2, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		rows := sqlmock.NewRows(columns).
+			AddRow(2, date1, 2000, "Dinner", "MOCK", "location/on/s3/bucket/eslip2", 1, "expense", "THB", 1, false).
+			AddRow(1, date2, 1000, "Lunch", "MOCK", "location/on/s3/bucket/eslip1", 1, "expense", "THB", 1, false)
+		mock.ExpectQuery(firstPage).WithArgs(txtype.Expense, "1", defaultLimit+1).WillReturnRows(rows)
 		h := New(db)
 		err := h.GetAllBySpender(c)
 
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
-		assert.JSONEq(t, `[{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":1000,"category":"Lunch","note":"MOCK","image_url":"location/on/s3/bucket/eslip1","spender_id":1,"transaction_type":"EXPENSE"},
-{"id":2,"date":"2024-05-18T15:51:49.673703Z","amount":2000,"category":"Dinner","note":"MOCK","image_url":"location/on/s3/bucket/eslip2","spender_id":2,"transaction_type":"EXPENSE"}]`, rec.Body.String())
+		assert.JSONEq(t, `{"transactions": [{"id":2,"date":"2024-05-18T15:51:49.673703Z","amount":2000,"category":"Dinner","note":"MOCK","image_url":"location/on/s3/bucket/eslip2","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false},
+{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":1000,"category":"Lunch","note":"MOCK","image_url":"location/on/s3/bucket/eslip1","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false}], "next_cursor": ""}`, rec.Body.String())
+	})
+	t.Run("get first page returns next cursor", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/spenders/1/transaction?transaction_type=EXPENSE&limit=1", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("spenderId")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		date1, _ := time.Parse(time.RFC3339, "2024-05-18T15:51:49.673703Z")
+		date2, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		rows := sqlmock.NewRows(columns).
+			AddRow(2, date1, 2000, "Dinner", "MOCK", "", 1, "expense", "THB", 1, false).
+			AddRow(1, date2, 1000, "Lunch", "MOCK", "", 1, "expense", "THB", 1, false)
+		mock.ExpectQuery(firstPage).WithArgs(txtype.Expense, "1", 2).WillReturnRows(rows)
+		h := New(db)
+		err := h.GetAllBySpender(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got pageResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Len(t, got.Transactions, 1)
+		assert.Equal(t, cursor{Date: date1, ID: 2}.encode(), got.NextCursor)
+	})
+	t.Run("get next page with cursor", func(t *testing.T) {
+		date, _ := time.Parse(time.RFC3339, "2024-05-18T15:51:49.673703Z")
+		next := cursor{Date: date, ID: 2}.encode()
+
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/spenders/1/transaction?transaction_type=EXPENSE&limit=1&cursor="+next, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("spenderId")
+		c.SetParamValues("1")
+
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		date2, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
+		rows := sqlmock.NewRows(columns).AddRow(1, date2, 1000, "Lunch", "MOCK", "", 1, "expense", "THB", 1, false)
+		mock.ExpectQuery(bySpenderStatement+` AND (date, id) < ($3, $4) ORDER BY date DESC, id DESC LIMIT $5`).
+			WithArgs(txtype.Expense, "1", date, 2, 2).WillReturnRows(rows)
+		h := New(db)
+		err := h.GetAllBySpender(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"transactions": [{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":1000,"category":"Lunch","note":"MOCK","image_url":"","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false}], "next_cursor": ""}`, rec.Body.String())
+	})
+	t.Run("get all expense fail invalid cursor", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/spenders/1/transaction?transaction_type=EXPENSE&cursor=not-a-cursor", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(nil)
+		err := h.GetAllBySpender(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":"invalid cursor"}`, rec.Body.String())
+	})
+	t.Run("get all expense fail invalid limit", func(t *testing.T) {
+		e := echo.New()
+		defer e.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/spenders/1/transaction?transaction_type=EXPENSE&limit=0", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := New(nil)
+		err := h.GetAllBySpender(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 	t.Run("get all expense fail incorrect transaction_type", func(t *testing.T) {
 		e := echo.New()
@@ -181,15 +401,9 @@ func TestGetAllExpense(t *testing.T) {
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		db, _, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		date1, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
-		date2, _ := time.Parse(time.RFC3339, "2024-05-18T15:51:49.673703Z")
-		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type"}).
-			AddRow(1, date1, 1000, "Lunch", "MOCK", "location/on/s3/bucket/eslip1", 1, "EXPENSE").
-			AddRow(2, date2, 2000, "Dinner", "MOCK", "location/on/s3/bucket/eslip2", 2, "EXPENSE")
-		mock.ExpectQuery(`SELECT id, date, amount, category, note, image_url, spender_id, transaction_type FROM transaction where transaction_type = $1`).WithArgs("EXPENSE").WillReturnRows(rows)
 		h := New(db)
 		err := h.GetAllBySpender(c)
 
@@ -208,7 +422,7 @@ func TestGetAllExpense(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		mock.ExpectQuery(`SELECT id, date, amount, category, note, image_url, spender_id, transaction_type FROM transaction where transaction_type = 'EXPENSE'`).WillReturnError(assert.AnError)
+		mock.ExpectQuery(firstPage).WillReturnError(assert.AnError)
 
 		h := New(db)
 		err := h.GetAllBySpender(c)
@@ -216,7 +430,7 @@ func TestGetAllExpense(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
-	t.Run("get all expense successfully", func(t *testing.T) {
+	t.Run("get all expense failed on scan", func(t *testing.T) {
 		e := echo.New()
 		defer e.Close()
 
@@ -227,10 +441,10 @@ func TestGetAllExpense(t *testing.T) {
 		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 		defer db.Close()
 
-		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type"}).
-			AddRow("", "", 1000, "Lunch", "MOCK", "location/on/s3/bucket/eslip1", 1, "EXPENSE").
-			AddRow("", "date2", 2000, "Dinner", "MOCK", "location/on/s3/bucket/eslip2", 2, "EXPENSE")
-		mock.ExpectQuery(`SELECT id, date, amount, category, note, image_url, spender_id, transaction_type FROM transaction where transaction_type = $1`).WithArgs("EXPENSE").WillReturnRows(rows)
+		rows := sqlmock.NewRows(columns).
+			AddRow("", "", 1000, "Lunch", "MOCK", "location/on/s3/bucket/eslip1", 1, "expense", "THB", 1, false).
+			AddRow("", "date2", 2000, "Dinner", "MOCK", "location/on/s3/bucket/eslip2", 2, "expense", "THB", 1, false)
+		mock.ExpectQuery(firstPage).WillReturnRows(rows)
 		h := New(db)
 		err := h.GetAllBySpender(c)
 
@@ -239,6 +453,22 @@ func TestGetAllExpense(t *testing.T) {
 	})
 }
 
+func TestCursor(t *testing.T) {
+	date, _ := time.Parse(time.RFC3339Nano, "2024-05-18T11:51:49.673703Z")
+	cur := cursor{Date: date, ID: 42}
+
+	got, err := decodeCursor(cur.encode())
+
+	assert.NoError(t, err)
+	assert.True(t, cur.Date.Equal(got.Date))
+	assert.Equal(t, cur.ID, got.ID)
+
+	for _, bad := range []string{"%%%", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		_, err := decodeCursor(bad)
+		assert.ErrorIs(t, err, errInvalidCursor, bad)
+	}
+}
+
 func TestUpdateTransaction(t *testing.T) {
 	t.Run("Update Transaction Successfully", func(t *testing.T) {
 		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -249,11 +479,50 @@ func TestUpdateTransaction(t *testing.T) {
 		req := mockTransactionRequest()
 		req.Date = date
 		c, rec := setupUpdateOrDeleteTest(http.MethodPut, req)
-		mock.ExpectExec(updateStatment).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
+		mock.ExpectQuery(updateStatment).WillReturnRows(mockTransactionRows(70))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionUpdate, audit.EntityTransaction, int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		h := New(db)
 		err = h.Update(c)
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+		assert.JSONEq(t, `{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":70,"category":"Food","note":"Note1234","image_url":"","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false}`, rec.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Update Transaction fail If-Match is stale", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		c, rec := setupUpdateOrDeleteTest(http.MethodPut, mockTransactionRequest())
+		c.Request().Header.Set("If-Match", `"2"`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
+		mock.ExpectRollback()
+		h := New(db)
+		err = h.Update(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Update Transaction fail transaction does not exist", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		c, rec := setupUpdateOrDeleteTest(http.MethodPut, mockTransactionRequest())
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(sqlmock.NewRows(transactionColumns))
+		mock.ExpectRollback()
+		h := New(db)
+		err = h.Update(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Update Transaction fail request body is invalid", func(t *testing.T) {
 		db, _, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -278,7 +547,7 @@ func TestUpdateTransaction(t *testing.T) {
 		}
 		date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49.673703Z")
 		req := mockTransactionRequest()
-		req.Amount = -1
+		req.Amount = money.MustParse("-1")
 		req.Date = date
 		c, rec := setupUpdateOrDeleteTest(http.MethodPut, req)
 		h := New(db)
@@ -308,7 +577,9 @@ func TestUpdateTransaction(t *testing.T) {
 		}
 		req := mockTransactionRequest()
 		c, rec := setupUpdateOrDeleteTest(http.MethodPut, req)
-		mock.ExpectExec(updateStatment).WillReturnError(errors.New("error"))
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
+		mock.ExpectQuery(updateStatment).WillReturnError(errors.New("error"))
 		h := New(db)
 		err = h.Update(c)
 		assert.NoError(t, err)
@@ -326,11 +597,17 @@ func TestDeleteTransaction(t *testing.T) {
 		req := mockTransactionRequest()
 		req.Date = date
 		c, rec := setupUpdateOrDeleteTest(http.MethodDelete, req)
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
 		mock.ExpectExec(deleteStatment).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionDelete, audit.EntityTransaction, int64(1), sqlmock.AnyArg(), nil, "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		h := New(db)
 		err = h.Delete(c)
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 	t.Run("Delete Transaction fail db error", func(t *testing.T) {
 		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -339,10 +616,59 @@ func TestDeleteTransaction(t *testing.T) {
 		}
 		req := mockTransactionRequest()
 		c, rec := setupUpdateOrDeleteTest(http.MethodDelete, req)
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
 		mock.ExpectExec(deleteStatment).WillReturnError(errors.New("error"))
 		h := New(db)
 		err = h.Delete(c)
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	})
+	t.Run("Delete Transaction fail If-Match is stale", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		if err != nil {
+			log.Fatal(err)
+		}
+		c, rec := setupUpdateOrDeleteTest(http.MethodDelete, mockTransactionRequest())
+		c.Request().Header.Set("If-Match", `"2"`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(findForUpdateStatement).WillReturnRows(mockTransactionRows(66.6))
+		mock.ExpectRollback()
+		h := New(db)
+		err = h.Delete(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetByID(t *testing.T) {
+	t.Run("get a transaction with its ETag", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupUpdateOrDeleteTest(http.MethodGet, request{})
+		c.SetParamNames("spenderId", "transId")
+		c.SetParamValues("1", "1")
+		mock.ExpectQuery(findStatement).WithArgs("1", "1").WillReturnRows(mockTransactionRows(66.6))
+
+		err := New(db).GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+		assert.JSONEq(t, `{"id":1,"date":"2024-05-18T11:51:49.673703Z","amount":66.6,"category":"Food","note":"Note1234","image_url":"","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false}`, rec.Body.String())
+	})
+	t.Run("get a missing transaction", func(t *testing.T) {
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		c, rec := setupUpdateOrDeleteTest(http.MethodGet, request{})
+		c.SetParamNames("spenderId", "transId")
+		c.SetParamValues("1", "9")
+		mock.ExpectQuery(findStatement).WithArgs("9", "1").WillReturnRows(sqlmock.NewRows(transactionColumns))
+
+		err := New(db).GetByID(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
 }
@@ -0,0 +1,163 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/kkgo-software-engineering/workshop/mlog"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	trashStatement   = `SELECT ` + columns + `, deleted_at FROM transaction WHERE spender_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC;`
+	restoreStatement = `UPDATE transaction SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND spender_id = $2 AND deleted_at IS NOT NULL
+RETURNING ` + columns
+	purgeStatement = `DELETE FROM transaction WHERE deleted_at < $1
+RETURNING ` + columns
+)
+
+type trashResponse struct {
+	response
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// GetTrash lists the deleted transactions of the spender that have not been
+// purged yet, most recently deleted first.
+func (h handler) GetTrash(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+
+	rows, err := h.db.QueryContext(ctx, trashStatement, c.Param("spenderId"))
+	if err != nil {
+		logger.Error("query error", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "query error"})
+	}
+	defer rows.Close()
+
+	res := []trashResponse{}
+	for rows.Next() {
+		var t trashResponse
+		err := rows.Scan(&t.Id, &t.Date, &t.Amount, &t.Category, &t.Note, &t.ImageUrl, &t.SpenderId, &t.TransactionType, &t.Currency, &t.Version, &t.Draft, &t.DeletedAt)
+		if err != nil {
+			logger.Error("scan error", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, transactionError{Message: "scan error"})
+		}
+		res = append(res, t)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Restore moves a deleted transaction out of the trash and returns it with its
+// new ETag, so a client holding the version from before the delete has to
+// read it again before patching it.
+func (h handler) Restore(c echo.Context) error {
+	logger := mlog.L(c)
+	ctx := c.Request().Context()
+	spenderId := c.Param("spenderId")
+	transId := c.Param("transId")
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("restore transaction", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "restore transaction error"})
+	}
+	defer tx.Rollback()
+
+	t, err := scanTransaction(tx.QueryRowContext(ctx, restoreStatement, transId, spenderId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, transactionError{Message: "transaction not found in trash"})
+	}
+	if err != nil {
+		logger.Error("restore transaction", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "restore transaction error"})
+	}
+	if err := h.commit(c, tx, audit.ActionRestore, t.Id, nil, t); err != nil {
+		logger.Error("restore transaction", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, transactionError{Message: "restore transaction error"})
+	}
+	c.Response().Header().Set(headerETag, etag(t.Version))
+	return c.JSON(http.StatusOK, t)
+}
+
+// Purger permanently removes transactions that have been in the trash for
+// longer than the retention period.
+type Purger struct {
+	db        *sql.DB
+	retention time.Duration
+	interval  time.Duration
+	logger    *zap.Logger
+	now       func() time.Time
+}
+
+func NewPurger(db *sql.DB, retention, interval time.Duration, logger *zap.Logger) *Purger {
+	return &Purger{db: db, retention: retention, interval: interval, logger: logger, now: time.Now}
+}
+
+// Start purges immediately and then on every interval until ctx is
+// cancelled.
+func (p *Purger) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.Run(ctx); err != nil {
+			p.logger.Error("purge deleted transactions", zap.Error(err))
+		} else if n > 0 {
+			p.logger.Info("purged deleted transactions", zap.Int64("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run removes every transaction deleted before the retention period and
+// reports how many were removed.
+func (p *Purger) Run(ctx context.Context) (int64, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, purgeStatement, p.now().Add(-p.retention))
+	if err != nil {
+		return 0, err
+	}
+	var purged []response
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		purged = append(purged, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, t := range purged {
+		err := audit.Write(ctx, tx, audit.Entry{
+			Action:   audit.ActionPurge,
+			Entity:   audit.EntityTransaction,
+			EntityID: int64(t.Id),
+			Before:   t,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(purged)), nil
+}
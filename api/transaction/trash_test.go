@@ -0,0 +1,118 @@
+package transaction
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/audit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTrashContext(method string, params ...string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("spenderId", "transId")
+	c.SetParamValues(params...)
+	return c, rec
+}
+
+func TestGetTrash(t *testing.T) {
+	t.Run("list deleted transactions", func(t *testing.T) {
+		c, rec := newTrashContext(http.MethodGet, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		date, _ := time.Parse(time.RFC3339, "2024-05-18T11:51:49Z")
+		deletedAt, _ := time.Parse(time.RFC3339, "2024-05-20T08:00:00Z")
+		rows := sqlmock.NewRows([]string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type", "currency", "version", "draft", "deleted_at"}).
+			AddRow(7, date, 1000, "Food", "Lunch", "", 1, "expense", "THB", 1, false, deletedAt)
+		mock.ExpectQuery(trashStatement).WithArgs("1").WillReturnRows(rows)
+
+		err := New(db).GetTrash(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"id":7,"date":"2024-05-18T11:51:49Z","amount":1000,"category":"Food","note":"Lunch","image_url":"","spender_id":1,"transaction_type":"expense","currency":"THB","version":1,"draft":false,"deleted_at":"2024-05-20T08:00:00Z"}]`, rec.Body.String())
+	})
+
+	t.Run("empty trash", func(t *testing.T) {
+		c, rec := newTrashContext(http.MethodGet, "1")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectQuery(trashStatement).WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "date", "amount", "category", "note", "image_url", "spender_id", "transaction_type", "currency", "version", "draft", "deleted_at"}))
+
+		err := New(db).GetTrash(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+}
+
+func TestRestore(t *testing.T) {
+	t.Run("restore a deleted transaction with a new version", func(t *testing.T) {
+		c, rec := newTrashContext(http.MethodPost, "1", "7")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(restoreStatement).WithArgs("7", "1").WillReturnRows(mockTransactionRows(66.6))
+		mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionRestore, audit.EntityTransaction, int64(1), nil, sqlmock.AnyArg(), "", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := New(db).Restore(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+		assert.Contains(t, rec.Body.String(), `"id":1`)
+		assert.Contains(t, rec.Body.String(), `"version":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("restore fails when the transaction is not in the trash", func(t *testing.T) {
+		c, rec := newTrashContext(http.MethodPost, "1", "7")
+		db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(restoreStatement).WithArgs("7", "1").WillReturnRows(sqlmock.NewRows(transactionColumns))
+
+		err := New(db).Restore(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestPurgerRun(t *testing.T) {
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+
+	now := time.Date(2024, time.June, 30, 0, 0, 0, 0, time.UTC)
+	p := NewPurger(db, 30*24*time.Hour, time.Hour, zap.NewNop())
+	p.now = func() time.Time { return now }
+	mock.ExpectBegin()
+	mock.ExpectQuery(purgeStatement).WithArgs(time.Date(2024, time.May, 31, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(mockTransactionRows(66.6))
+	mock.ExpectExec(audit.InsertStmt).WithArgs(nil, audit.ActionPurge, audit.EntityTransaction, int64(1), sqlmock.AnyArg(), nil, "", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	n, err := p.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
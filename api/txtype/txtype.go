@@ -0,0 +1,48 @@
+package txtype
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// TransactionType says whether a transaction takes money out of or puts money
+// into a spender's account. The lower-case values are the only ones stored;
+// the migrations enforce them with a CHECK constraint.
+type TransactionType string
+
+const (
+	Expense TransactionType = "expense"
+	Income  TransactionType = "income"
+)
+
+var ErrInvalid = errors.New("invalid transaction type")
+
+func normalize(s string) TransactionType {
+	return TransactionType(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// Parse accepts a transaction type in any letter case, such as "EXPENSE".
+func Parse(s string) (TransactionType, error) {
+	t := normalize(s)
+	if !t.Valid() {
+		return "", ErrInvalid
+	}
+	return t, nil
+}
+
+func (t TransactionType) Valid() bool {
+	return t == Expense || t == Income
+}
+
+// UnmarshalJSON normalizes the letter case of a request value. It does not
+// reject unknown values so handlers can report them with their usual error
+// body; call Valid for that.
+func (t *TransactionType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*t = normalize(s)
+	return nil
+}
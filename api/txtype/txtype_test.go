@@ -0,0 +1,40 @@
+package txtype
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]TransactionType{
+		"expense":  Expense,
+		"EXPENSE":  Expense,
+		" Income ": Income,
+	} {
+		got, err := Parse(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "transfer", "expenses"} {
+		_, err := Parse(in)
+		assert.ErrorIs(t, err, ErrInvalid, in)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var v struct {
+		Type TransactionType `json:"transaction_type"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"transaction_type": "INCOME"}`), &v))
+	assert.Equal(t, Income, v.Type)
+	assert.True(t, v.Type.Valid())
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"transaction_type": "Transfer"}`), &v))
+	assert.False(t, v.Type.Valid())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"transaction_type": 1}`), &v))
+}
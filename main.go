@@ -9,7 +9,13 @@ import (
 	"time"
 
 	"github.com/KKGo-Software-engineering/workshop-summer/api"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/auth"
 	"github.com/KKGo-Software-engineering/workshop-summer/api/config"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/currency"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/recurring"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/slipjob"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/storage"
+	"github.com/KKGo-Software-engineering/workshop-summer/api/transaction"
 	"github.com/KKGo-Software-engineering/workshop-summer/migration"
 	"github.com/labstack/gommon/log"
 	_ "github.com/lib/pq"
@@ -33,7 +39,20 @@ func main() {
 		log.Fatal(err)
 	}
 
-	e := api.New(db, cfg, logger)
+	if file := cfg.Currency.ExchangeRateFile; file != "" {
+		n, err := currency.LoadFile(context.Background(), db, file)
+		if err != nil {
+			logger.Fatal("loading exchange rates:", zap.Error(err))
+		}
+		logger.Info("exchange rates loaded", zap.String("file", file), zap.Int("count", n))
+	}
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		logger.Fatal("configuring storage:", zap.Error(err))
+	}
+
+	e := api.New(db, cfg, store, logger)
 
 	go func() { // comment here to simulate slow endpoint then Ctrl+C to stop the server
 		if err := e.Start(":" + cfg.Server.Port); err != nil && err != http.ErrServerClosed {
@@ -47,6 +66,11 @@ func main() {
 	sig, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
+	go recurring.NewScheduler(db, cfg.Scheduler.RecurringInterval, logger).Start(sig)
+	go transaction.NewPurger(db, cfg.Scheduler.TrashRetention, cfg.Scheduler.PurgeInterval, logger).Start(sig)
+	go auth.NewPurger(db, cfg.Scheduler.RevokedTokenPurgeInterval, logger).Start(sig)
+	go slipjob.NewWorker(db, store, slipjob.Blank, cfg.Scheduler, logger).Start(sig)
+
 	<-sig.Done()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "spender" ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'spender';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "spender" DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
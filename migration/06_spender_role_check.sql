@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "spender" ADD CONSTRAINT spender_role_check CHECK (role IN ('admin', 'spender'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "spender" DROP CONSTRAINT IF EXISTS spender_role_check;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS "recurring" (
+	id SERIAL PRIMARY KEY,
+	spender_id INT NOT NULL,
+	amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+	category VARCHAR(50) NOT NULL DEFAULT '',
+	transaction_type VARCHAR(20) NOT NULL DEFAULT '',
+	note VARCHAR(255) NOT NULL DEFAULT '',
+	frequency VARCHAR(10) NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly', 'yearly')),
+	start_date DATE NOT NULL,
+	end_date DATE,
+	last_run DATE,
+	paused BOOLEAN NOT NULL DEFAULT FALSE
+);
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS recurring_id INT;
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_recurring_date_idx ON "transaction" (recurring_id, date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS transaction_recurring_date_idx;
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS recurring_id;
+DROP TABLE IF EXISTS "recurring";
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'THB';
+ALTER TABLE "spender" ADD COLUMN IF NOT EXISTS base_currency VARCHAR(3) NOT NULL DEFAULT 'THB';
+
+CREATE TABLE IF NOT EXISTS "exchange_rate" (
+	base_currency VARCHAR(3) NOT NULL,
+	quote_currency VARCHAR(3) NOT NULL,
+	rate_date DATE NOT NULL,
+	rate NUMERIC(18,8) NOT NULL CHECK (rate > 0),
+	PRIMARY KEY (base_currency, quote_currency, rate_date)
+);
+
+-- transaction_base adds the amount of each transaction converted into the
+-- base currency of its spender, using the latest rate published on or before
+-- the transaction date. A rate quoted the other way round is inverted.
+-- base_amount is NULL when no rate is known, so such rows are left out of
+-- converted totals instead of being added as if they were in base currency.
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW IF EXISTS transaction_base;
+DROP TABLE IF EXISTS "exchange_rate";
+ALTER TABLE "spender" DROP COLUMN IF EXISTS base_currency;
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS currency;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+UPDATE "transaction" SET transaction_type = lower(trim(transaction_type))
+WHERE transaction_type <> lower(trim(transaction_type));
+UPDATE "recurring" SET transaction_type = lower(trim(transaction_type))
+WHERE transaction_type <> lower(trim(transaction_type));
+
+ALTER TABLE "transaction" ALTER COLUMN transaction_type DROP DEFAULT;
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_type_check CHECK (transaction_type IN ('expense', 'income'));
+ALTER TABLE "recurring" ALTER COLUMN transaction_type DROP DEFAULT;
+ALTER TABLE "recurring" ADD CONSTRAINT recurring_transaction_type_check CHECK (transaction_type IN ('expense', 'income'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "recurring" DROP CONSTRAINT IF EXISTS recurring_transaction_type_check;
+ALTER TABLE "recurring" ALTER COLUMN transaction_type SET DEFAULT '';
+ALTER TABLE "transaction" DROP CONSTRAINT IF EXISTS transaction_type_check;
+ALTER TABLE "transaction" ALTER COLUMN transaction_type SET DEFAULT '';
+-- +goose StatementEnd
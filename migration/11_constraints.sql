@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Spenders sharing an email cannot satisfy the unique email. The oldest one
+-- keeps it and the others are moved aside first, so their transactions,
+-- budgets and recurrences are moved aside with the other orphans below.
+CREATE TABLE IF NOT EXISTS "spender_duplicate" AS SELECT * FROM "spender" WITH NO DATA;
+INSERT INTO "spender_duplicate"
+SELECT s.* FROM "spender" s
+WHERE EXISTS (SELECT 1 FROM "spender" o WHERE o.email = s.email AND o.id < s.id);
+DELETE FROM "spender" s
+WHERE EXISTS (SELECT 1 FROM "spender" o WHERE o.email = s.email AND o.id < s.id);
+
+-- Transactions without a date or an existing spender cannot satisfy the new
+-- constraints. They are moved aside rather than dropped so they can still be
+-- inspected and re-attached by hand.
+CREATE TABLE IF NOT EXISTS "transaction_orphan" AS SELECT * FROM "transaction" WITH NO DATA;
+INSERT INTO "transaction_orphan"
+SELECT t.* FROM "transaction" t
+WHERE t.date IS NULL OR t.spender_id IS NULL
+	OR NOT EXISTS (SELECT 1 FROM "spender" s WHERE s.id = t.spender_id);
+DELETE FROM "transaction" t
+WHERE t.date IS NULL OR t.spender_id IS NULL
+	OR NOT EXISTS (SELECT 1 FROM "spender" s WHERE s.id = t.spender_id);
+
+ALTER TABLE "transaction" ALTER COLUMN date SET NOT NULL;
+ALTER TABLE "transaction" ALTER COLUMN spender_id SET NOT NULL;
+ALTER TABLE "transaction" ALTER COLUMN transaction_type SET NOT NULL;
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_spender_fk
+	FOREIGN KEY (spender_id) REFERENCES "spender" (id) ON DELETE CASCADE;
+
+-- Budgets and recurrences of missing spenders are moved aside the same way.
+CREATE TABLE IF NOT EXISTS "budget_orphan" AS SELECT * FROM "budget" WITH NO DATA;
+INSERT INTO "budget_orphan"
+SELECT b.* FROM "budget" b WHERE NOT EXISTS (SELECT 1 FROM "spender" s WHERE s.id = b.spender_id);
+DELETE FROM "budget" b WHERE NOT EXISTS (SELECT 1 FROM "spender" s WHERE s.id = b.spender_id);
+ALTER TABLE "budget" ADD CONSTRAINT budget_spender_fk
+	FOREIGN KEY (spender_id) REFERENCES "spender" (id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS "recurring_orphan" AS SELECT * FROM "recurring" WITH NO DATA;
+INSERT INTO "recurring_orphan"
+SELECT r.* FROM "recurring" r WHERE NOT EXISTS (SELECT 1 FROM "spender" s WHERE s.id = r.spender_id);
+DELETE FROM "recurring" r WHERE NOT EXISTS (SELECT 1 FROM "spender" s WHERE s.id = r.spender_id);
+ALTER TABLE "recurring" ADD CONSTRAINT recurring_spender_fk
+	FOREIGN KEY (spender_id) REFERENCES "spender" (id) ON DELETE CASCADE;
+
+ALTER TABLE "spender" ADD CONSTRAINT spender_email_key UNIQUE (email);
+
+CREATE INDEX IF NOT EXISTS transaction_spender_type_date_idx ON "transaction" (spender_id, transaction_type, date, id);
+CREATE INDEX IF NOT EXISTS transaction_date_idx ON "transaction" (date, id);
+CREATE INDEX IF NOT EXISTS recurring_spender_idx ON "recurring" (spender_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS recurring_spender_idx;
+DROP INDEX IF EXISTS transaction_date_idx;
+DROP INDEX IF EXISTS transaction_spender_type_date_idx;
+ALTER TABLE "spender" DROP CONSTRAINT IF EXISTS spender_email_key;
+ALTER TABLE "recurring" DROP CONSTRAINT IF EXISTS recurring_spender_fk;
+ALTER TABLE "budget" DROP CONSTRAINT IF EXISTS budget_spender_fk;
+ALTER TABLE "transaction" DROP CONSTRAINT IF EXISTS transaction_spender_fk;
+ALTER TABLE "transaction" ALTER COLUMN transaction_type DROP NOT NULL;
+ALTER TABLE "transaction" ALTER COLUMN spender_id DROP NOT NULL;
+ALTER TABLE "transaction" ALTER COLUMN date DROP NOT NULL;
+INSERT INTO "spender" SELECT * FROM "spender_duplicate";
+DROP TABLE IF EXISTS "spender_duplicate";
+INSERT INTO "transaction" SELECT * FROM "transaction_orphan";
+DROP TABLE IF EXISTS "transaction_orphan";
+INSERT INTO "recurring" SELECT * FROM "recurring_orphan";
+DROP TABLE IF EXISTS "recurring_orphan";
+INSERT INTO "budget" SELECT * FROM "budget_orphan";
+DROP TABLE IF EXISTS "budget_orphan";
+-- +goose StatementEnd
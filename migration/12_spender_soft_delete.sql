@@ -0,0 +1,76 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "spender" ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- A soft-deleted spender must not keep their email reserved.
+ALTER TABLE "spender" DROP CONSTRAINT IF EXISTS spender_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS spender_email_key ON "spender" (email) WHERE deleted_at IS NULL;
+
+-- Summaries and budget status read from transaction_base, so hiding the
+-- transactions of soft-deleted spenders here hides them everywhere.
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id AND s.deleted_at IS NULL
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE;
+
+-- Soft-deleted spenders may share an email with an active one, which the
+-- plain unique constraint cannot hold, so they are removed for good.
+DELETE FROM "spender" WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS spender_email_key;
+ALTER TABLE "spender" ADD CONSTRAINT spender_email_key UNIQUE (email);
+ALTER TABLE "spender" DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
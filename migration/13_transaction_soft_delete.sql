@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS transaction_deleted_at_idx ON "transaction" (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id AND s.deleted_at IS NULL
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE
+WHERE t.deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id AND s.deleted_at IS NULL
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE;
+
+-- Trashed rows would reappear once the column is gone.
+DELETE FROM "transaction" WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS transaction_deleted_at_idx;
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- actor_id has no foreign key on purpose: the trail must survive the hard
+-- deletion of the spender who made, or was subject to, a change.
+CREATE TABLE IF NOT EXISTS "audit_log" (
+	id SERIAL PRIMARY KEY,
+	actor_id INT,
+	action VARCHAR(20) NOT NULL,
+	entity VARCHAR(20) NOT NULL,
+	entity_id INT NOT NULL,
+	before JSONB,
+	after JSONB,
+	parent_id TEXT NOT NULL DEFAULT '',
+	span_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON "audit_log" (created_at, id);
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON "audit_log" (entity, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON "audit_log" (actor_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "audit_log";
+-- +goose StatementEnd
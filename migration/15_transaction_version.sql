@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- version is bumped on every update and backs the ETag of a transaction.
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
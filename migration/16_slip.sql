@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- slip is an uploaded e-slip image. A transaction can have any number of
+-- slips; transaction.image_url is kept for clients that still set it.
+CREATE TABLE IF NOT EXISTS "slip" (
+	id SERIAL PRIMARY KEY,
+	spender_id INT NOT NULL REFERENCES "spender" (id) ON DELETE CASCADE,
+	transaction_id INT REFERENCES "transaction" (id) ON DELETE SET NULL,
+	storage_key TEXT NOT NULL,
+	location TEXT NOT NULL,
+	filename VARCHAR(255) NOT NULL DEFAULT '',
+	content_type VARCHAR(50) NOT NULL,
+	size BIGINT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS slip_spender_id_idx ON "slip" (spender_id);
+CREATE INDEX IF NOT EXISTS slip_transaction_id_idx ON "slip" (transaction_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "slip";
+-- +goose StatementEnd
@@ -0,0 +1,91 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A draft is a transaction made from a slip that the spender has not saved
+-- yet. Drafts are listed but left out of summaries and budgets.
+ALTER TABLE "transaction" ADD COLUMN IF NOT EXISTS draft BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id AND s.deleted_at IS NULL
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE
+WHERE t.deleted_at IS NULL AND NOT t.draft;
+
+-- slip_job is the queue of uploaded slips waiting for extraction. Workers
+-- claim due jobs with FOR UPDATE SKIP LOCKED; a running job whose run_at has
+-- passed was left by a worker that stopped and is claimed again.
+CREATE TABLE IF NOT EXISTS "slip_job" (
+	id SERIAL PRIMARY KEY,
+	slip_id INT NOT NULL REFERENCES "slip" (id) ON DELETE CASCADE,
+	status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL CHECK (max_attempts > 0),
+	run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error TEXT NOT NULL DEFAULT '',
+	transaction_id INT REFERENCES "transaction" (id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS slip_job_due_idx ON "slip_job" (run_at, id) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS slip_job_slip_id_idx ON "slip_job" (slip_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "slip_job";
+
+CREATE OR REPLACE VIEW transaction_base AS
+SELECT
+	t.id,
+	t.date,
+	t.amount,
+	t.currency,
+	t.category,
+	t.transaction_type,
+	t.spender_id,
+	s.base_currency,
+	CASE
+		WHEN t.currency = s.base_currency THEN t.amount
+		ELSE ROUND(t.amount * r.rate, 2)
+	END AS base_amount
+FROM "transaction" t
+JOIN "spender" s ON s.id = t.spender_id AND s.deleted_at IS NULL
+LEFT JOIN LATERAL (
+	SELECT x.rate FROM (
+		SELECT rate_date, rate FROM "exchange_rate"
+		WHERE base_currency = t.currency AND quote_currency = s.base_currency AND rate_date <= t.date::date
+		UNION ALL
+		SELECT rate_date, 1 / rate FROM "exchange_rate"
+		WHERE base_currency = s.base_currency AND quote_currency = t.currency AND rate_date <= t.date::date
+	) x
+	ORDER BY x.rate_date DESC
+	LIMIT 1
+) r ON TRUE
+WHERE t.deleted_at IS NULL;
+
+-- Drafts would count as confirmed once the column is gone.
+DELETE FROM "transaction" WHERE draft;
+ALTER TABLE "transaction" DROP COLUMN IF EXISTS draft;
+-- +goose StatementEnd
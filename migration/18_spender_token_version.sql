@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- token_version is carried by every token issued to the spender. Bumping it,
+-- on a password change or deletion, invalidates all their outstanding tokens.
+ALTER TABLE "spender" ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "spender" DROP COLUMN IF EXISTS token_version;
+-- +goose StatementEnd
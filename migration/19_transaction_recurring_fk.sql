@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A deleted recurrence keeps the transactions it created; they only lose the
+-- link. Links to recurrences deleted before the key existed are dropped the
+-- same way first.
+UPDATE "transaction" t SET recurring_id = NULL
+WHERE t.recurring_id IS NOT NULL
+	AND NOT EXISTS (SELECT 1 FROM "recurring" r WHERE r.id = t.recurring_id);
+ALTER TABLE "transaction" ADD CONSTRAINT transaction_recurring_fk
+	FOREIGN KEY (recurring_id) REFERENCES "recurring" (id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "transaction" DROP CONSTRAINT IF EXISTS transaction_recurring_fk;
+-- +goose StatementEnd